@@ -0,0 +1,286 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/aaronland/go-pagination/countable"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BrowseOptions defines options for the `Browse` method.
+type BrowseOptions struct {
+	// An optional pagination.Options instance used to limit the results returned. If nil all the results are returned.
+	Pagination pagination.Options
+	// The order in which results are returned. Results are always ordered by ID last. If empty results are ordered by ID.
+	// Results can not be sorted by relevance since there is no search term.
+	Sort []*SortOrder
+	// An optional list of property paths (see `ValidatePropertyPath`) whose values are read from the properties table and added to each result.
+	Extras []string
+}
+
+// DefaultBrowseOptions returns a new BrowseOptions instance that will return all the results ordered by ID.
+func DefaultBrowseOptions() (*BrowseOptions, error) {
+
+	opts := &BrowseOptions{
+		Sort: make([]*SortOrder, 0),
+	}
+
+	return opts, nil
+}
+
+// Browse returns the records matching 'filters', without a search term, ordered and paginated according to 'opts'. Filters
+// are applied to the spr table in SQL where possible (see `SQLFilter`) in which case the results are paginated in SQL as well.
+// Otherwise all the matching records are retrieved and filtered before they are paginated.
+func (ftdb *SQLiteFullTextDatabase) Browse(ctx context.Context, opts *BrowseOptions, filters ...filter.Filter) (wof_spr.StandardPlacesResults, pagination.Results, error) {
+
+	if opts == nil {
+
+		default_opts, err := DefaultBrowseOptions()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		opts = default_opts
+	}
+
+	if opts.Pagination != nil && opts.Pagination.Method() != pagination.Countable {
+		return nil, nil, errors.New("Unsupported pagination method")
+	}
+
+	if !sortInSQL(opts.Sort) {
+		return nil, nil, errors.New("Browse results can not be sorted by relevance")
+	}
+
+	err := validateSortOrders(opts.Sort)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ev := &QueryEvent{
+		Database: ftdb.db.DSN(),
+	}
+
+	t1 := time.Now()
+
+	ranked, pg, err := ftdb.browseWithEvent(ctx, opts, ev, filters...)
+
+	ev.Latency = time.Since(t1)
+	ev.Results = len(ranked)
+	ev.Error = err
+
+	for _, o := range ftdb.observers {
+		o.ObserveQuery(ctx, ev)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r_opts *QueryOptions
+
+	if len(opts.Extras) > 0 {
+		r_opts = &QueryOptions{
+			Extras: opts.Extras,
+		}
+	}
+
+	r, err := resultsFromRankedResults(ctx, ranked, ranked, r_opts)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, pg, nil
+}
+
+// browseWithEvent does the work of Browse recording the details of the query in 'ev'.
+func (ftdb *SQLiteFullTextDatabase) browseWithEvent(ctx context.Context, opts *BrowseOptions, ev *QueryEvent, filters ...filter.Filter) ([]*rankedResult, pagination.Results, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where := []string{
+		"s.alt_label = ''",
+	}
+
+	args := make([]interface{}, 0)
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	for _, f := range sql_filters {
+
+		clause, clause_args := f.WhereSQL("s")
+
+		if clause == "" {
+			continue
+		}
+
+		where = append(where, clause)
+		args = append(args, clause_args...)
+
+		ev.SQLFilters = append(ev.SQLFilters, describeFilter(f))
+	}
+
+	for _, f := range go_filters {
+		ev.GoFilters = append(ev.GoFilters, describeFilter(f))
+	}
+
+	str_where := strings.Join(where, " AND ")
+
+	order_by, order_args := orderBySQL("s", opts.Sort)
+
+	q := fmt.Sprintf("SELECT s.id FROM %s s WHERE %s ORDER BY %s", ftdb.spr_table.Name(), str_where, order_by)
+	q_args := append(append([]interface{}{}, args...), order_args...)
+
+	// If every filter is applied in SQL then the total number of results can be counted, and the current
+	// page selected, in SQL. Otherwise the results are paginated after they have been filtered.
+
+	paginate_sql := opts.Pagination != nil && len(go_filters) == 0
+
+	var pg pagination.Results
+
+	if paginate_sql {
+
+		t_count := time.Now()
+
+		count_q := fmt.Sprintf("SELECT COUNT(s.id) FROM %s s WHERE %s", ftdb.spr_table.Name(), str_where)
+
+		var total int64
+
+		err := conn.QueryRowContext(ctx, count_q, args...).Scan(&total)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ev.addStage("count", t_count)
+
+		count_pg, err := countable.NewResultsFromCountWithOptions(opts.Pagination, total)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		page := int64(math.Max(1.0, float64(countable.PageFromOptions(opts.Pagination))))
+		per_page := count_pg.PerPage()
+
+		q = fmt.Sprintf("%s LIMIT ? OFFSET ?", q)
+		q_args = append(q_args, per_page, (page-1)*per_page)
+
+		pg = count_pg
+	}
+
+	ev.SQL = q
+	ev.args = q_args
+
+	t_match := time.Now()
+
+	ids, err := ftdb.browseIds(ctx, conn, q, q_args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ev.addStage("match", t_match)
+	ev.RowsMatched = len(ids)
+
+	ranked := make([]*rankedResult, 0)
+
+	t_fetch := time.Now()
+
+	for _, str_id := range ids {
+
+		id, err := strconv.ParseInt(str_id, 10, 64)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid ID '%s', %w", str_id, err)
+		}
+
+		spr_r, err := spr.RetrieveSPR(ctx, ftdb.db, ftdb.spr_table, id, "")
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to retrieve SPR for %s, %w", str_id, err)
+		}
+
+		if !matchesFilters(spr_r, go_filters...) {
+			continue
+		}
+
+		ranked = append(ranked, &rankedResult{
+			SPR:    spr_r,
+			Score:  1.0,
+			Index:  len(ranked),
+			source: ftdb,
+		})
+	}
+
+	ev.SPRFetchTime = time.Since(t_fetch)
+	ev.addStage("fetch", t_fetch)
+
+	if opts.Pagination != nil && !paginate_sql {
+
+		t_paginate := time.Now()
+
+		page, page_pg, err := paginateRankedResults(ranked, opts.Pagination)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ev.addStage("paginate", t_paginate)
+
+		ranked = page
+		pg = page_pg
+	}
+
+	return ranked, pg, nil
+}
+
+// browseIds returns the IDs of the rows returned by 'q' which is expected to select a single ID column.
+func (ftdb *SQLiteFullTextDatabase) browseIds(ctx context.Context, conn *sql.DB, q string, args ...interface{}) ([]string, error) {
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]string, 0)
+
+	for rows.Next() {
+
+		var id string
+
+		err := rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,256 @@
+package sqlite
+
+import (
+	"container/list"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/aaronland/go-pagination/countable"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats reports the number of hits and misses for a query cache as well as the number of entries it currently contains.
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// queryCache is a size-bounded least-recently-used cache for query results with an optional time-to-live for entries.
+type queryCache struct {
+	mu     *sync.Mutex
+	size   int
+	ttl    time.Duration
+	items  map[string]*list.Element
+	lru    *list.List
+	hits   int64
+	misses int64
+	// generation is incremented every time the cache is purged so that results computed before an invalidation aren't cached after it
+	generation int64
+}
+
+type queryCacheEntry struct {
+	key        string
+	results    wof_spr.StandardPlacesResults
+	pagination pagination.Results
+	created    time.Time
+}
+
+// newQueryCache returns a new queryCache instance that will store at most 'size' entries. If 'ttl' is greater
+// than zero entries older than 'ttl' are treated as misses.
+func newQueryCache(size int, ttl time.Duration) *queryCache {
+
+	c := &queryCache{
+		mu:    new(sync.Mutex),
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+
+	return c
+}
+
+func (c *queryCache) Get(key string) (wof_spr.StandardPlacesResults, pagination.Results, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+
+	if !ok {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, nil, false
+	}
+
+	e := el.Value.(*queryCacheEntry)
+
+	if c.ttl > 0 && time.Since(e.created) > c.ttl {
+		c.lru.Remove(el)
+		delete(c.items, key)
+		atomic.AddInt64(&c.misses, 1)
+		return nil, nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	atomic.AddInt64(&c.hits, 1)
+
+	return e.results, e.pagination, true
+}
+
+// Generation returns the current generation of the cache. It should be recorded before a query is performed and passed to Set.
+func (c *queryCache) Generation() int64 {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// Set stores 'results' and 'pg' for 'key' unless the cache has been purged since 'generation'.
+func (c *queryCache) Set(key string, generation int64, results wof_spr.StandardPlacesResults, pg pagination.Results) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	el, ok := c.items[key]
+
+	if ok {
+		e := el.Value.(*queryCacheEntry)
+		e.results = results
+		e.pagination = pg
+		e.created = time.Now()
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	e := &queryCacheEntry{
+		key:        key,
+		results:    results,
+		pagination: pg,
+		created:    time.Now(),
+	}
+
+	c.items[key] = c.lru.PushFront(e)
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*queryCacheEntry).key)
+	}
+}
+
+// Purge removes all the entries in the cache. Hit and miss counts are not reset.
+func (c *queryCache) Purge() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.lru.Init()
+	c.generation += 1
+}
+
+func (c *queryCache) Stats() CacheStats {
+
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	stats := CacheStats{
+		Hits:    atomic.LoadInt64(&c.hits),
+		Misses:  atomic.LoadInt64(&c.misses),
+		Entries: entries,
+	}
+
+	return stats
+}
+
+// queryCacheKey returns a string used to cache the results for 'term', 'opts' and 'filters'. The second return value
+// will be false if any of the filters can not be serialized in which case the query should not be cached.
+func queryCacheKey(term string, opts *QueryOptions, filters ...filter.Filter) (string, bool) {
+
+	// Plain text terms are quoted before they are matched so their case and whitespace don't affect the results, unlike raw
+	// terms whose full-text operators (OR, NOT, NEAR) are case-sensitive
+
+	if opts.MatchMode != RawMatch {
+		term = strings.Join(strings.Fields(strings.ToLower(term)), " ")
+	}
+
+	parts := []string{
+		fmt.Sprintf("term=%s", term),
+		fmt.Sprintf("match=%s", opts.MatchMode),
+	}
+
+	if opts.Pagination != nil {
+		page := countable.PageFromOptions(opts.Pagination)
+		parts = append(parts, fmt.Sprintf("page=%d,%d", page, opts.Pagination.PerPage()))
+	}
+
+	if opts.Labels != nil {
+		parts = append(parts, fmt.Sprintf("labels=%s,%t,%q", strings.Join(opts.Labels.Placetypes, ","), opts.Labels.ShortNames, opts.Labels.Separator))
+	}
+
+	if len(opts.Facets) > 0 {
+		parts = append(parts, fmt.Sprintf("facets=%s", strings.Join(opts.Facets, ",")))
+	}
+
+	if len(opts.Sort) > 0 {
+
+		sorts := make([]string, len(opts.Sort))
+
+		for i, s := range opts.Sort {
+			sorts[i] = s.String()
+		}
+
+		parts = append(parts, fmt.Sprintf("sort=%s", strings.Join(sorts, ",")))
+	}
+
+	if len(opts.SynonymLanguages) > 0 {
+		parts = append(parts, fmt.Sprintf("synonyms=%s", strings.Join(opts.SynonymLanguages, ",")))
+	}
+
+	if len(opts.Extras) > 0 {
+		parts = append(parts, fmt.Sprintf("extras=%s", strings.Join(opts.Extras, ",")))
+	}
+
+	if opts.Hierarchical {
+		parts = append(parts, "hierarchical=true")
+	}
+
+	if opts.Importance != nil {
+		parts = append(parts, fmt.Sprintf("importance=%s", opts.Importance))
+	}
+
+	if opts.Focus != nil {
+		parts = append(parts, fmt.Sprintf("focus=%s", opts.Focus))
+	}
+
+	for _, f := range filters {
+
+		str_f, ok := filterCacheKey(f)
+
+		if !ok {
+			return "", false
+		}
+
+		parts = append(parts, str_f)
+	}
+
+	return strings.Join(parts, "#"), true
+}
+
+func filterCacheKey(f filter.Filter) (string, bool) {
+
+	switch f.(type) {
+	case *filter.SPRFilter:
+
+		spr_f := f.(*filter.SPRFilter)
+
+		parts := []string{
+			fmt.Sprintf("placetypes=%v", spr_f.Placetypes),
+			fmt.Sprintf("current=%v", spr_f.Current),
+			fmt.Sprintf("deprecated=%v", spr_f.Deprecated),
+			fmt.Sprintf("ceased=%v", spr_f.Ceased),
+			fmt.Sprintf("superseded=%v", spr_f.Superseded),
+			fmt.Sprintf("superseding=%v", spr_f.Superseding),
+			fmt.Sprintf("alt=%v", spr_f.AlternateGeometry),
+			fmt.Sprintf("alts=%v", spr_f.AlternateGeometries),
+		}
+
+		return strings.Join(parts, ";"), true
+
+	case fmt.Stringer:
+		return fmt.Sprintf("%T:%s", f, f.(fmt.Stringer).String()), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestQueryCacheRawMatch(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(1, "Paris", 48.86, 2.35, nil),
+		testFeature(2, "London", 51.51, -0.13, nil),
+	}
+
+	ftdb := newTestDatabase(t, "cache_size=10", features...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	opts.MatchMode = RawMatch
+
+	// OR is an operator but "or" is a search term so these queries must not share a cache key
+
+	ids := sortedIds(queryIds(t, ftdb, "paris OR london", opts))
+
+	if !equalIds(ids, []string{"1", "2"}) {
+		t.Fatalf("Expected 'paris OR london' to match [1 2] but got %v", ids)
+	}
+
+	ids = queryIds(t, ftdb, "paris or london", opts)
+
+	if len(ids) != 0 {
+		t.Errorf("Expected 'paris or london' not to match anything but got %v", ids)
+	}
+
+	stats, _ := ftdb.CacheStats()
+
+	if stats.Hits != 0 {
+		t.Errorf("Expected no cache hits for raw queries differing in case but got %d", stats.Hits)
+	}
+}
+
+func TestQueryCachePlainMatch(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "cache_size=10", testFeature(1, "Paris", 48.86, 2.35, nil))
+
+	// Plain text terms differing only in case and whitespace share a cache key
+
+	for _, term := range []string{"Paris", " paris ", "PARIS"} {
+
+		ids := queryIds(t, ftdb, term, nil)
+
+		if !equalIds(ids, []string{"1"}) {
+			t.Errorf("Expected '%s' to match [1] but got %v", term, ids)
+		}
+	}
+
+	stats, _ := ftdb.CacheStats()
+
+	if stats.Hits != 2 {
+		t.Errorf("Expected 2 cache hits but got %d", stats.Hits)
+	}
+}
@@ -0,0 +1,352 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModifiedFilter restricts results to records whose lastmodified date falls within a range. It is applied in SQL using the
+// `lastmodified` column of the spr table.
+type ModifiedFilter struct {
+	nullFilter
+	// Only match records modified at or after this Unix timestamp. Zero means no lower bound.
+	Since int64
+	// Only match records modified before this Unix timestamp. Zero means no upper bound.
+	Before int64
+}
+
+// NewModifiedFilter returns a new `ModifiedFilter` for records modified at or after 'since' and before 'before', both of which
+// are Unix timestamps. Either may be zero to leave that end of the range open.
+func NewModifiedFilter(since int64, before int64) (*ModifiedFilter, error) {
+
+	if since < 0 || before < 0 {
+		return nil, errors.New("Modified dates must be positive")
+	}
+
+	if since > 0 && before > 0 && before <= since {
+		return nil, errors.New("Modified before date must be later than modified since date")
+	}
+
+	f := &ModifiedFilter{
+		Since:  since,
+		Before: before,
+	}
+
+	return f, nil
+}
+
+// ParseModifiedTime parses 'str' as either a Unix timestamp or an RFC 3339 date, returning a Unix timestamp. An empty string returns zero.
+func ParseModifiedTime(str string) (int64, error) {
+
+	if str == "" {
+		return 0, nil
+	}
+
+	ts, err := strconv.ParseInt(str, 10, 64)
+
+	if err == nil {
+		return ts, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid modified date '%s', expected a Unix timestamp or an RFC 3339 date", str)
+	}
+
+	return t.Unix(), nil
+}
+
+func (f *ModifiedFilter) WhereSQL(alias string) (string, []interface{}) {
+
+	clauses := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if f.Since > 0 {
+		clauses = append(clauses, fmt.Sprintf("%s.lastmodified >= ?", alias))
+		args = append(args, f.Since)
+	}
+
+	if f.Before > 0 {
+		clauses = append(clauses, fmt.Sprintf("%s.lastmodified < ?", alias))
+		args = append(args, f.Before)
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+func (f *ModifiedFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+
+	lastmod := s.LastModified()
+
+	if f.Since > 0 && lastmod < f.Since {
+		return false
+	}
+
+	if f.Before > 0 && lastmod >= f.Before {
+		return false
+	}
+
+	return true
+}
+
+func (f *ModifiedFilter) String() string {
+	return fmt.Sprintf("modified_since=%d;modified_before=%d", f.Since, f.Before)
+}
+
+// ListChanges returns the records matching 'term' and 'filters' in ascending order of their lastmodified date (and then ID). If
+// 'term' is empty all records are listed. Results are paginated using 'pg_opts' which must be a `CursorOptions` instance (or nil
+// for the first page with the default number of results per page). The cursor for the next page, if there is one, is returned by
+// the `Next` method of the pagination.Results instance. Cursors remain stable as records are added or modified, although a record
+// modified after a page was fetched will appear again in a later page.
+func (ftdb *SQLiteFullTextDatabase) ListChanges(ctx context.Context, term string, pg_opts pagination.Options, filters ...filter.Filter) (wof_spr.StandardPlacesResults, pagination.Results, error) {
+
+	if pg_opts == nil {
+
+		opts, err := NewCursorOptions()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pg_opts = opts
+	}
+
+	if pg_opts.Method() != pagination.Cursor {
+		return nil, nil, errors.New("Unsupported pagination method")
+	}
+
+	per_page := pg_opts.PerPage()
+
+	if per_page < 1 {
+		return nil, nil, errors.New("Invalid number of results per page")
+	}
+
+	var cursor *changesCursor
+
+	str_cursor, _ := pg_opts.Pointer().(string)
+
+	if str_cursor != "" {
+
+		c, err := parseChangesCursor(str_cursor)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cursor = c
+	}
+
+	ev := &QueryEvent{
+		Database: ftdb.db.DSN(),
+		Term:     term,
+	}
+
+	t1 := time.Now()
+
+	ranked, next, err := ftdb.listChangesWithEvent(ctx, term, cursor, per_page, ev, filters...)
+
+	ev.Latency = time.Since(t1)
+	ev.Results = len(ranked)
+	ev.Error = err
+
+	for _, o := range ftdb.observers {
+		o.ObserveQuery(ctx, ev)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := resultsFromRankedResults(ctx, ranked, ranked, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pg := &CursorResults{
+		PerPageCount: per_page,
+	}
+
+	if next != nil {
+		pg.NextCursor = next.String()
+	}
+
+	return r, pg, nil
+}
+
+// listChangesWithEvent does the work of ListChanges recording the details of the query in 'ev'. Rows are read in batches
+// until 'per_page' results have passed any filters that could not be applied in SQL, or there are no more rows.
+func (ftdb *SQLiteFullTextDatabase) listChangesWithEvent(ctx context.Context, term string, cursor *changesCursor, per_page int64, ev *QueryEvent, filters ...filter.Filter) ([]*rankedResult, *changesCursor, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where := []string{
+		"s.alt_label = ''",
+	}
+
+	args := make([]interface{}, 0)
+
+	if term != "" {
+
+		match, err := matchExpression(term, PlainTextMatch, ftdb.tokenExpanders(nil)...)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		where = append(where, fmt.Sprintf("s.id IN (SELECT CAST(id AS TEXT) FROM %s WHERE names_all MATCH ? OR id MATCH ?)", ftdb.search_table.Name()))
+		args = append(args, match, match)
+
+		ev.Match = match
+	}
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	for _, f := range sql_filters {
+
+		clause, clause_args := f.WhereSQL("s")
+
+		if clause == "" {
+			continue
+		}
+
+		where = append(where, clause)
+		args = append(args, clause_args...)
+
+		ev.SQLFilters = append(ev.SQLFilters, describeFilter(f))
+	}
+
+	for _, f := range go_filters {
+		ev.GoFilters = append(ev.GoFilters, describeFilter(f))
+	}
+
+	// Fetch one more result than necessary to determine whether there is another page
+	want := int(per_page) + 1
+
+	ranked := make([]*rankedResult, 0)
+	positions := make([]*changesCursor, 0)
+
+	t_fetch := time.Now()
+
+	for len(ranked) < want {
+
+		q_where := append([]string{}, where...)
+		q_args := append([]interface{}{}, args...)
+
+		if cursor != nil {
+			q_where = append(q_where, "(s.lastmodified > ? OR (s.lastmodified = ? AND s.id > ?))")
+			q_args = append(q_args, cursor.LastModified, cursor.LastModified, cursor.Id)
+		}
+
+		q := fmt.Sprintf("SELECT s.id, s.lastmodified FROM %s s WHERE %s ORDER BY s.lastmodified ASC, s.id ASC LIMIT ?", ftdb.spr_table.Name(), strings.Join(q_where, " AND "))
+		q_args = append(q_args, want)
+
+		ev.SQL = q
+		ev.args = q_args
+
+		batch, err := ftdb.changesBatch(ctx, conn, q, q_args...)
+
+		if err != nil {
+			return nil, nil, wrapMatchError(term, err)
+		}
+
+		ev.RowsMatched += len(batch)
+
+		for _, c := range batch {
+
+			id, err := strconv.ParseInt(c.Id, 10, 64)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Invalid ID '%s', %w", c.Id, err)
+			}
+
+			spr_r, err := spr.RetrieveSPR(ctx, ftdb.db, ftdb.spr_table, id, "")
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to retrieve SPR for %s, %w", c.Id, err)
+			}
+
+			if !matchesFilters(spr_r, go_filters...) {
+				continue
+			}
+
+			ranked = append(ranked, &rankedResult{
+				SPR:    spr_r,
+				Score:  1.0,
+				Index:  len(ranked),
+				source: ftdb,
+			})
+
+			positions = append(positions, c)
+
+			if len(ranked) == want {
+				break
+			}
+		}
+
+		if len(batch) < want {
+			break
+		}
+
+		cursor = batch[len(batch)-1]
+	}
+
+	ev.SPRFetchTime = time.Since(t_fetch)
+	ev.addStage("fetch", t_fetch)
+
+	if len(ranked) < want {
+		return ranked, nil, nil
+	}
+
+	ranked = ranked[:per_page]
+	return ranked, positions[per_page-1], nil
+}
+
+// changesBatch returns the position of each of the rows returned by 'q' which is expected to select an ID and a lastmodified date.
+func (ftdb *SQLiteFullTextDatabase) changesBatch(ctx context.Context, conn *sql.DB, q string, args ...interface{}) ([]*changesCursor, error) {
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	batch := make([]*changesCursor, 0)
+
+	for rows.Next() {
+
+		c := new(changesCursor)
+
+		err := rows.Scan(&c.Id, &c.LastModified)
+
+		if err != nil {
+			return nil, err
+		}
+
+		batch = append(batch, c)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isCJK returns a boolean value indicating whether 'r' is a Chinese, Japanese or Korean character. The full-text tokenizer
+// treats a run of these characters as a single token since they are not separated by spaces.
+func isCJK(r rune) bool {
+
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return true
+	case r == 'ー', r == '々', r == '〆':
+		// The prolonged sound mark and the iteration marks are common to several scripts
+		return true
+	default:
+		return false
+	}
+}
+
+// cjkRuns returns the runs of consecutive CJK characters in 'str'.
+func cjkRuns(str string) []string {
+
+	return strings.FieldsFunc(str, func(r rune) bool {
+		return !isCJK(r)
+	})
+}
+
+// cjkBigrams returns the overlapping pairs of characters in 'run', for example "東京都" becomes "東京" and "京都".
+func cjkBigrams(run string) []string {
+
+	chars := []rune(run)
+	bigrams := make([]string, 0)
+
+	for i := 0; i+1 < len(chars); i++ {
+		bigrams = append(bigrams, string(chars[i:i+2]))
+	}
+
+	return bigrams
+}
+
+// cjkIndexedForms returns the tokens indexed for each run of two or more CJK characters in 'names', so that they can be
+// matched by any part of the run. Each run is indexed as its bigrams followed by its final character, in a single string so
+// that they are adjacent in the index, for example "東京 京都 都". Search terms are matched against the bigrams as a phrase
+// (see `cjkExpander`) and single characters as either the final character of a run or the prefix of a bigram.
+func cjkIndexedForms(names string) []string {
+
+	forms := make([]string, 0)
+
+	for _, run := range cjkRuns(names) {
+
+		chars := []rune(run)
+
+		if len(chars) < 2 {
+			continue
+		}
+
+		tokens := append(cjkBigrams(run), string(chars[len(chars)-1]))
+		form := strings.Join(tokens, " ")
+
+		if !stringInList(form, forms) {
+			forms = append(forms, form)
+		}
+	}
+
+	return forms
+}
+
+// cjkExpander returns a `tokenExpander` that matches search terms made of CJK characters against the tokens added by
+// `cjkIndexedForms`, so that "東京" matches "東京都" and "京都" matches "東京都" as well as "京都府".
+func cjkExpander() tokenExpander {
+
+	return func(token string) []string {
+
+		for _, r := range token {
+
+			if !isCJK(r) {
+				return nil
+			}
+		}
+
+		chars := []rune(token)
+
+		switch len(chars) {
+		case 0:
+			return nil
+		case 1:
+			// Any bigram starting with the character, the trailing character of a run is already matched by the token itself
+			return []string{
+				token + "*",
+			}
+		case 2:
+			// The token is itself a bigram
+			return nil
+		default:
+			return []string{
+				strings.Join(cjkBigrams(token), " "),
+			}
+		}
+	}
+}
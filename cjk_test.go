@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+// cjkFeatures returns Chinese, Japanese and Korean localities whose names are indexed as CJK bigrams.
+func cjkFeatures() [][]byte {
+
+	return [][]byte{
+		testFeature(1, "Tokyo", 35.69, 139.69, map[string]interface{}{
+			"name:jpn_x_preferred": []string{"東京都"},
+		}),
+		testFeature(2, "Kyoto", 35.01, 135.77, map[string]interface{}{
+			"name:jpn_x_preferred": []string{"京都府"},
+		}),
+		testFeature(3, "Seoul", 37.57, 126.98, map[string]interface{}{
+			"name:kor_x_preferred": []string{"서울"},
+		}),
+		testFeature(4, "Beijing", 39.91, 116.39, map[string]interface{}{
+			"name:zho_x_preferred": []string{"北京"},
+		}),
+	}
+}
+
+func TestCJKBigrams(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "cjk_bigrams=true", cjkFeatures()...)
+
+	tests := []struct {
+		Term     string
+		Expected []string
+	}{
+		// A prefix of 東京都
+		{Term: "東京", Expected: []string{"1"}},
+		// A partial name which occurs in both 京都府 and 東京都
+		{Term: "京都", Expected: []string{"1", "2"}},
+		// Whole names
+		{Term: "서울", Expected: []string{"3"}},
+		{Term: "北京", Expected: []string{"4"}},
+		{Term: "東京都", Expected: []string{"1"}},
+	}
+
+	for _, test := range tests {
+
+		ids := sortedIds(queryIds(t, ftdb, test.Term, nil))
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected '%s' to match %v but got %v", test.Term, test.Expected, ids)
+		}
+	}
+}
+
+func TestCJKWithoutBigrams(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "", cjkFeatures()...)
+
+	// Without bigrams the full-text tokenizer treats 東京都 as a single token which 東京 does not match
+	ids := queryIds(t, ftdb, "東京", nil)
+
+	if len(ids) != 0 {
+		t.Errorf("Expected '東京' not to match without CJK bigrams but got %v", ids)
+	}
+
+	ids = queryIds(t, ftdb, "東京都", nil)
+
+	if !equalIds(ids, []string{"1"}) {
+		t.Errorf("Expected '東京都' to match [1] without CJK bigrams but got %v", ids)
+	}
+}
@@ -5,14 +5,73 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	_ "github.com/whosonfirst/go-whosonfirst-search-sqlite"
+	"github.com/aaronland/go-pagination"
+	"github.com/aaronland/go-pagination/countable"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/whosonfirst/go-whosonfirst-search-sqlite"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
 	"github.com/whosonfirst/go-whosonfirst-search/fulltext"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
 	"log"
+	"os"
+	"strings"
 )
 
+// optionsDatabase is implemented by the databases in this package that support `sqlite.QueryOptions`.
+type optionsDatabase interface {
+	QueryStringWithOptions(context.Context, string, *sqlite.QueryOptions, ...filter.Filter) (wof_spr.StandardPlacesResults, pagination.Results, error)
+}
+
+// observerDatabase is implemented by the databases in this package that support `sqlite.Observer` instances.
+type observerDatabase interface {
+	AddObserver(sqlite.Observer)
+}
+
 func main() {
 
 	db_uri := flag.String("fulltext-database-uri", "null://", "...")
+	explain := flag.Bool("explain", false, "Output a description of how each query was performed, including SQLite's query plan, alongside its results. This is only supported by sqlite:// databases.")
+	labels := flag.Bool("labels", false, "Add a human-readable label, derived from the names of its ancestors, to each result. This requires that the database has an ancestors table.")
+	label_placetypes := flag.String("label-placetypes", "locality,region,country", "A comma-separated list of the ancestor placetypes to include in labels.")
+	label_short_names := flag.Bool("label-short-names", false, "Use short names (for example \"QC\" or \"CA\") for ancestors in labels, where available.")
+	facets := flag.String("facets", "", "An optional comma-separated list of facets to count across all the results for a query. Valid options are: placetype, country, repo, is_current, is_deprecated, is_ceased, is_superseded, is_superseding or \"all\".")
+	placetype := flag.String("placetype", "", "An optional comma-separated list of placetypes to restrict results to.")
+	is_current := flag.String("is-current", "", "An optional comma-separated list of existential flag values (1, 0 or -1) to restrict results to based on whether a record is current.")
+	parent := flag.String("parent", "", "An optional comma-separated list of Who's On First IDs. Only records whose immediate parent is one of these IDs will be returned.")
+	ancestor := flag.String("ancestor", "", "An optional comma-separated list of Who's On First IDs. Only records that belong to one of these IDs, at any depth, will be returned.")
+	country := flag.String("country", "", "An optional comma-separated list of ISO 3166-1 alpha-2 country codes to restrict results to. Codes prefixed with \"-\" are excluded, for example \"-US\".")
+	repo := flag.String("repo", "", "An optional comma-separated list of repository names to restrict results to. Names prefixed with \"-\" are excluded.")
+	existed := flag.String("existed", "", "An optional EDTF date or date range. Only records that existed at some point during this date will be returned.")
+	incepted := flag.String("incepted", "", "An optional EDTF date or date range. Only records whose inception date falls within this date will be returned.")
+	ceased := flag.String("ceased", "", "An optional EDTF date or date range. Only records whose cessation date falls within this date will be returned.")
+	temporal_mode := flag.String("temporal-mode", "possibly", "How uncertain, approximate and unknown dates are treated by the -existed, -incepted and -ceased flags. Valid options are: possibly (records that may match are returned), definitely (only records that match under every reading of their dates are returned).")
+	modified_since := flag.String("modified-since", "", "An optional Unix timestamp or RFC 3339 date. Only records modified at or after this date will be returned.")
+	modified_before := flag.String("modified-before", "", "An optional Unix timestamp or RFC 3339 date. Only records modified before this date will be returned.")
+	changes := flag.Bool("changes", false, "List records in ascending order of their lastmodified date, rather than by relevance. Search terms are optional. This is only supported by sqlite:// databases.")
+	cursor := flag.String("cursor", "", "The cursor for the next page of results when using the -changes flag.")
+	structured := flag.String("structured", "", "An optional structured query, a semi-colon separated list of {FIELD}={NAME} pairs, for example \"locality=Montreal;region=Quebec\". Valid fields are: neighbourhood, locality, county, region, country. Each name is matched against records with the corresponding placetypes whose ancestors match the less specific fields. This is only supported by sqlite:// databases.")
+	ids := flag.Bool("ids", false, "Treat each argument as a Who's On First ID, or a comma-separated list of IDs, and return the records for those IDs that match the filters defined by other flags along with the IDs that are not in the database. This is only supported by sqlite:// databases.")
+	browse := flag.Bool("browse", false, "List the records matching the filters defined by other flags, without a search term. This is only supported by sqlite:// databases.")
+	sort := flag.String("sort", "", "An optional comma-separated list of fields to order results by. Valid options are: relevance, id, name, lastmodified, placetype, distance or a property path, for example wof:population. Fields prefixed with \"-\" are sorted in reverse order. The default is relevance, or id when using the -browse flag.")
+	sort_latitude := flag.String("sort-latitude", "", "The latitude of the point that results are sorted by distance from.")
+	sort_longitude := flag.String("sort-longitude", "", "The longitude of the point that results are sorted by distance from.")
+	sort_locale := flag.String("sort-locale", "", "An optional BCP 47 language tag, for example sv or de, whose conventions are used to sort results by name. If empty names are sorted ignoring case and the accents of Latin letters.")
+	page := flag.Int64("page", 1, "The page of results to return when using the -browse flag.")
+	per_page := flag.Int64("per-page", sqlite.CURSOR_PER_PAGE, "The number of results per page when using the -changes or -browse flags.")
+	format := flag.String("format", "spr", "The format of the results. Valid options are: spr (standard places results), geojson (a GeoJSON FeatureCollection of the original records, which requires that the database has a geojson table).")
+	geometry := flag.String("geometry", "full", "The geometry included with each feature when using -format geojson. Valid options are: full (the original geometry), bbox (the bounding box of the original geometry), point (the centroid).")
+	extras := flag.String("extras", "", "An optional comma-separated list of property paths, for example wof:population, whose values are added to each result. This requires that the database has a properties table.")
+	property := flag.String("property", "", "An optional property filter expression, for example \"wof:population>1000000\". Valid operators are: =, !=, <, <=, >, >=. This requires that the database has a properties table.")
+	hierarchical := flag.Bool("hierarchical", false, "If true, also split search terms in to the name of a place and the names of its ancestors, for example \"paris texas\" or \"London, Ontario\", and add the places whose ancestors match to the results. This requires a database with an ancestors table.")
+	importance := flag.String("importance", "", "An optional list of weights used to boost the relevance of important records, either \"default\" or a comma-separated list of {SIGNAL}={WEIGHT} pairs, for example population=0.2,current=0.1. Valid signals are: population, megacity, placetype, current. Population and megacity are read from the properties table.")
+	focus_latitude := flag.String("focus-latitude", "", "The latitude of an optional point, for example the position of a user, that results near it are boosted relative to. Results are not excluded by their distance from it.")
+	focus_longitude := flag.String("focus-longitude", "", "The longitude of an optional point that results near it are boosted relative to.")
+	focus_scale := flag.Float64("focus-scale", 50.0, "The distance, in kilometres, from the point defined by the -focus-latitude and -focus-longitude flags at which results receive half of the boost.")
+	focus_weight := flag.Float64("focus-weight", 0.15, "The fraction, between 0.0 and 0.15, by which the relevance of results distant from the point defined by the -focus-latitude and -focus-longitude flags is lowered.")
+	synonym_languages := flag.String("synonym-languages", "", "An optional comma-separated list of language codes, for example eng,fra, whose synonyms are used to expand search terms. The default is all the languages in the database's synonym dictionary.")
+	metrics := flag.Bool("metrics", false, "Write metrics for the queries performed, in the Prometheus text format, to STDERR before exiting. This is only supported by sqlite:// and sqlite-multi:// databases.")
+	match_mode := flag.String("match-mode", "plain", "How search terms are matched. Valid options are: plain (full-text query syntax is matched literally), raw (search terms are treated as full-text query expressions), phonetic (as plain but also matching names that sound like the search terms, this requires a database created with the phonetic=true parameter).")
 
 	flag.Parse()
 
@@ -24,14 +83,496 @@ func main() {
 		log.Fatal(err)
 	}
 
-	for _, q := range flag.Args() {
+	if *metrics {
+
+		observer_db, ok := db.(observerDatabase)
+
+		if !ok {
+			log.Fatalf("The -metrics flag is not supported by %s databases", *db_uri)
+		}
+
+		prometheus := sqlite.NewPrometheusObserver()
+		observer_db.AddObserver(prometheus)
+
+		defer func() {
+
+			err := prometheus.WriteMetrics(os.Stderr)
+
+			if err != nil {
+				log.Printf("Failed to write metrics, %v", err)
+			}
+		}()
+	}
+
+	opts, err := sqlite.DefaultQueryOptions()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mode, err := sqlite.ParseMatchMode(*match_mode)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.MatchMode = mode
+
+	opts.Hierarchical = *hierarchical
+
+	if *importance != "" {
+
+		weights, err := sqlite.ParseImportanceWeights(*importance)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		opts.Importance = weights
+	}
+
+	if *focus_latitude != "" || *focus_longitude != "" {
+
+		focus, err := sqlite.ParseFocusPoint(*focus_latitude, *focus_longitude)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		focus.Scale = *focus_scale
+		focus.Weight = *focus_weight
+
+		err = focus.Validate()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		opts.Focus = focus
+	}
+
+	if *synonym_languages != "" {
+		opts.SynonymLanguages = strings.Split(*synonym_languages, ",")
+	}
+
+	if *labels {
+
+		label_opts := sqlite.DefaultLabelOptions()
+		label_opts.Placetypes = strings.Split(*label_placetypes, ",")
+		label_opts.ShortNames = *label_short_names
+
+		opts.Labels = label_opts
+	}
+
+	switch *facets {
+	case "":
+		// pass
+	case "all":
+		opts.Facets = sqlite.AllFacets
+	default:
+		opts.Facets = strings.Split(*facets, ",")
+	}
+
+	extra_paths, err := sqlite.ParsePropertyPaths(*extras)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts.Extras = extra_paths
+
+	var feature_opts *sqlite.FeatureOptions
+
+	switch *format {
+	case "spr":
+		// pass
+	case "geojson":
+
+		if *explain {
+			log.Fatal("The -explain flag can not be used with -format geojson")
+		}
+
+		geometry_mode, err := sqlite.ParseGeometryMode(*geometry)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		feature_opts = sqlite.DefaultFeatureOptions()
+		feature_opts.Geometry = geometry_mode
+
+	default:
+		log.Fatalf("Invalid format '%s'", *format)
+	}
+
+	var sort_point *orb.Point
+
+	if *sort_latitude != "" || *sort_longitude != "" {
+
+		pt, err := sqlite.ParseSortPoint(*sort_latitude, *sort_longitude)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sort_point = pt
+	}
+
+	sorts, err := sqlite.ParseSortOrders(*sort, sort_point)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *sort_locale != "" {
+
+		err := sqlite.SetSortLocale(sorts, *sort_locale)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	opts.Sort = sorts
+
+	filters := make([]filter.Filter, 0)
+
+	if *placetype != "" || *is_current != "" {
+
+		inputs, err := filter.NewSPRInputs()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *placetype != "" {
+			inputs.Placetypes = []string{*placetype}
+		}
+
+		if *is_current != "" {
+			inputs.IsCurrent = []string{*is_current}
+		}
+
+		f, err := filter.NewSPRFilterFromInputs(inputs)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *parent != "" {
+
+		f, err := sqlite.NewParentFilter(*parent)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *ancestor != "" {
+
+		f, err := sqlite.NewAncestorFilter(*ancestor)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *country != "" {
+
+		f, err := sqlite.NewCountryFilter(*country)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *repo != "" {
+
+		f, err := sqlite.NewRepoFilter(*repo)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *property != "" {
+
+		f, err := sqlite.NewPropertyFilter(*property)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *modified_since != "" || *modified_before != "" {
+
+		since, err := sqlite.ParseModifiedTime(*modified_since)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		before, err := sqlite.ParseModifiedTime(*modified_before)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		f, err := sqlite.NewModifiedFilter(since, before)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	t_mode, err := sqlite.ParseTemporalMode(*temporal_mode)
+
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		r, err := db.QueryString(ctx, q)
+	temporal := map[sqlite.TemporalConstraint]string{
+		sqlite.ExistedConstraint:  *existed,
+		sqlite.InceptedConstraint: *incepted,
+		sqlite.CeasedConstraint:   *ceased,
+	}
+
+	for constraint, edtf_str := range temporal {
+
+		if edtf_str == "" {
+			continue
+		}
+
+		f, err := sqlite.NewTemporalFilter(constraint, edtf_str, t_mode)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filters = append(filters, f)
+	}
+
+	if *changes {
+
+		sqlite_db, ok := db.(*sqlite.SQLiteFullTextDatabase)
+
+		if !ok {
+			log.Fatalf("The -changes flag is not supported by %s databases", *db_uri)
+		}
+
+		pg_opts, err := sqlite.NewCursorOptions()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pg_opts.PerPage(*per_page)
+		pg_opts.Pointer(*cursor)
+
+		term := strings.Join(flag.Args(), " ")
+
+		rsp, pg, err := sqlite_db.ListChanges(ctx, term, pg_opts, filters...)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var r interface{}
+
+		if feature_opts != nil {
+
+			fc, err := featureCollection(ctx, rsp, pg, feature_opts)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r = fc
+
+		} else {
+
+			r = map[string]interface{}{
+				"places":     rsp.Results(),
+				"pagination": pg,
+			}
+		}
+
+		enc_r, err := json.Marshal(r)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(string(enc_r))
+		return
+	}
+
+	if *browse {
+
+		sqlite_db, ok := db.(*sqlite.SQLiteFullTextDatabase)
+
+		if !ok {
+			log.Fatalf("The -browse flag is not supported by %s databases", *db_uri)
+		}
+
+		browse_opts, err := sqlite.DefaultBrowseOptions()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		browse_opts.Sort = sorts
+		browse_opts.Extras = extra_paths
+
+		pg_opts, err := countable.NewCountableOptions()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pg_opts.PerPage(*per_page)
+		pg_opts.Pointer(*page)
+
+		browse_opts.Pagination = pg_opts
+
+		rsp, pg, err := sqlite_db.Browse(ctx, browse_opts, filters...)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var r interface{}
+
+		if feature_opts != nil {
+
+			fc, err := featureCollection(ctx, rsp, pg, feature_opts)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r = fc
+
+		} else {
+
+			r = map[string]interface{}{
+				"places":     rsp.Results(),
+				"pagination": pg,
+			}
+		}
+
+		enc_r, err := json.Marshal(r)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(string(enc_r))
+		return
+	}
+
+	if *ids {
+
+		sqlite_db, ok := db.(*sqlite.SQLiteFullTextDatabase)
+
+		if !ok {
+			log.Fatalf("The -ids flag is not supported by %s databases", *db_uri)
+		}
+
+		wof_ids, err := sqlite.ParseIds(flag.Args()...)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		rsp, missing, err := sqlite_db.GetByIds(ctx, wof_ids, filters...)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var r interface{}
+
+		r = &sqlite.GetByIdsResponse{
+			Places:  rsp.Results(),
+			Missing: missing,
+		}
+
+		if feature_opts != nil {
+
+			for _, id := range missing {
+				log.Printf("Record %d is not in the database", id)
+			}
+
+			fc, err := featureCollection(ctx, rsp, nil, feature_opts)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r = fc
+		}
+
+		enc_r, err := json.Marshal(r)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(string(enc_r))
+		return
+	}
+
+	if *structured != "" {
+
+		sqlite_db, ok := db.(*sqlite.SQLiteFullTextDatabase)
+
+		if !ok {
+			log.Fatalf("The -structured flag is not supported by %s databases", *db_uri)
+		}
+
+		sq, err := sqlite.ParseStructuredQuery(*structured)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		rsp, _, err := sqlite_db.QueryStructured(ctx, sq, opts, filters...)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var r interface{}
+
+		r = rsp
+
+		if feature_opts != nil {
+
+			fc, err := featureCollection(ctx, rsp, nil, feature_opts)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r = fc
+		}
+
 		enc_r, err := json.Marshal(r)
 
 		if err != nil {
@@ -39,5 +580,90 @@ func main() {
 		}
 
 		fmt.Println(string(enc_r))
+		return
 	}
+
+	for _, q := range flag.Args() {
+
+		var r interface{}
+
+		if *explain {
+
+			sqlite_db, ok := db.(*sqlite.SQLiteFullTextDatabase)
+
+			if !ok {
+				log.Fatalf("The -explain flag is not supported by %s databases", *db_uri)
+			}
+
+			ex, err := sqlite_db.ExplainQueryString(ctx, q, opts, filters...)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r = ex
+
+		} else {
+
+			opts_db, ok := db.(optionsDatabase)
+
+			if !ok {
+				log.Fatalf("Query options are not supported by %s databases", *db_uri)
+			}
+
+			rsp, _, err := opts_db.QueryStringWithOptions(ctx, q, opts, filters...)
+
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			r = rsp
+
+			if feature_opts != nil {
+
+				fc, err := featureCollection(ctx, rsp, nil, feature_opts)
+
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				r = fc
+			}
+		}
+
+		enc_r, err := json.Marshal(r)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(string(enc_r))
+	}
+}
+
+// featureCollection returns 'rsp' as a GeoJSON FeatureCollection. If 'pg' is not nil it is added to the FeatureCollection as a `pagination` member.
+func featureCollection(ctx context.Context, rsp wof_spr.StandardPlacesResults, pg pagination.Results, opts *sqlite.FeatureOptions) (*geojson.FeatureCollection, error) {
+
+	search_rsp, ok := rsp.(*sqlite.SearchResults)
+
+	if !ok {
+		return nil, fmt.Errorf("Unsupported results type %T", rsp)
+	}
+
+	fc, err := search_rsp.FeatureCollection(ctx, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if pg != nil {
+
+		if fc.ExtraMembers == nil {
+			fc.ExtraMembers = geojson.Properties{}
+		}
+
+		fc.ExtraMembers["pagination"] = pg
+	}
+
+	return fc, nil
 }
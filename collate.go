@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The name of the database/sql driver used to open databases. It is the default SQLite driver with the collations
+// defined in this package registered for each connection.
+const sqliteDriver = "sqlite3_whosonfirst_search"
+
+// The name of the SQLite collation used to sort names. Locale-specific collations append the locale to this name (see `localeCollationName`).
+const nameCollation = "wof_name"
+
+// localeCollator wraps a `collate.Collator`, which is not safe for concurrent use, with a mutex.
+type localeCollator struct {
+	mu       *sync.Mutex
+	collator *collate.Collator
+}
+
+// The locales that names can be sorted for (see `collate.Supported`).
+var collationLocales []language.Tag
+
+// collationMatcher matches the locales requested for sorting to the closest of collationLocales.
+var collationMatcher language.Matcher
+
+// localeCollators caches the `localeCollator` instances for each locale that names have been sorted for, keyed by locale.
+var localeCollators map[string]*localeCollator
+
+var localeCollators_mu *sync.Mutex
+
+// foldedRunes maps lower case Latin letters with diacritics to their base letters. It is a fixed table which is used for every language.
+var foldedRunes map[rune]string
+
+func init() {
+
+	// Locales with a Unicode extension, for example German phonebook order ("de-u-co-phonebk"), are listed after the others so that
+	// they are only matched when requested explicitly
+
+	collationLocales = make([]language.Tag, 0)
+	extensions := make([]language.Tag, 0)
+
+	for _, t := range collate.Supported() {
+
+		if strings.Contains(t.String(), "-u-") {
+			extensions = append(extensions, t)
+		} else {
+			collationLocales = append(collationLocales, t)
+		}
+	}
+
+	collationLocales = append(collationLocales, extensions...)
+	collationMatcher = language.NewMatcher(collationLocales)
+
+	localeCollators = make(map[string]*localeCollator)
+	localeCollators_mu = new(sync.Mutex)
+
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+
+			err := conn.RegisterCollation(nameCollation, compareNames)
+
+			if err != nil {
+				return err
+			}
+
+			for _, t := range collationLocales {
+
+				locale := t.String()
+
+				compare := func(a string, b string) int {
+					return compareLocaleNames(locale, a, b)
+				}
+
+				err := conn.RegisterCollation(localeCollationName(locale), compare)
+
+				if err != nil {
+					return fmt.Errorf("Failed to register collation for '%s', %w", locale, err)
+				}
+			}
+
+			return nil
+		},
+	})
+
+	base := map[string]string{
+		"a":  "àáâãäåāăąǎ",
+		"c":  "çćĉċč",
+		"d":  "ďđð",
+		"e":  "èéêëēĕėęě",
+		"g":  "ĝğġģ",
+		"h":  "ĥħ",
+		"i":  "ìíîïĩīĭįı",
+		"j":  "ĵ",
+		"k":  "ķ",
+		"l":  "ĺļľŀł",
+		"n":  "ñńņňŉ",
+		"o":  "òóôõöøōŏőǒ",
+		"r":  "ŕŗř",
+		"s":  "śŝşšș",
+		"t":  "ţťŧț",
+		"u":  "ùúûüũūŭůűųǔ",
+		"w":  "ŵ",
+		"y":  "ýÿŷ",
+		"z":  "źżž",
+		"ss": "ß",
+		"ae": "æ",
+		"oe": "œ",
+		"th": "þ",
+	}
+
+	foldedRunes = make(map[rune]string)
+
+	for b, runes := range base {
+
+		for _, r := range runes {
+			foldedRunes[r] = b
+		}
+	}
+}
+
+// compareNames compares 'a' and 'b' by their folded forms (see `foldName`), rather than by byte value. Names are first compared
+// ignoring case and the accents of Latin letters (so "Échirolles" sorts alongside "Echirolles" rather than after "Zurich") and then,
+// if they are otherwise equal, by accents and finally by case. It returns -1, 0 or 1. The comparison is the same for every language,
+// so for example "Ö" sorts with "O" even in Swedish, and other scripts are compared by code point. Use `compareLocaleNames` to compare
+// names according to the conventions of a specific locale.
+func compareNames(a string, b string) int {
+
+	c := strings.Compare(foldName(a), foldName(b))
+
+	if c != 0 {
+		return c
+	}
+
+	c = strings.Compare(strings.ToLower(a), strings.ToLower(b))
+
+	if c != 0 {
+		return c
+	}
+
+	// Lower case sorts before upper case
+	return strings.Compare(b, a)
+}
+
+// foldName returns 'name' in lower case with the diacritics removed from any Latin letters.
+func foldName(name string) string {
+
+	var sb strings.Builder
+
+	for _, r := range name {
+
+		r = unicode.ToLower(r)
+
+		folded, ok := foldedRunes[r]
+
+		if ok {
+			sb.WriteString(folded)
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// compareLocaleNames compares 'a' and 'b' using the Unicode Collation Algorithm as tailored for 'locale', which is expected to be
+// one of the locales returned by `matchCollationLocale`. For example "Örebro" sorts after "Zagreb" in Swedish ("sv") but before it
+// in German ("de"). It returns -1, 0 or 1.
+func compareLocaleNames(locale string, a string, b string) int {
+
+	lc := getLocaleCollator(locale)
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return lc.collator.CompareString(a, b)
+}
+
+// getLocaleCollator returns the `localeCollator` instance for 'locale', creating it if necessary.
+func getLocaleCollator(locale string) *localeCollator {
+
+	localeCollators_mu.Lock()
+	defer localeCollators_mu.Unlock()
+
+	lc, ok := localeCollators[locale]
+
+	if !ok {
+
+		lc = &localeCollator{
+			mu:       new(sync.Mutex),
+			collator: collate.New(language.Make(locale)),
+		}
+
+		localeCollators[locale] = lc
+	}
+
+	return lc
+}
+
+// matchCollationLocale returns the locale, of those that names can be sorted for, that best matches the BCP 47 language tag 'str',
+// for example "sv" for "sv-SE". It returns an error if 'str' is not a valid language tag or no locale matches it.
+func matchCollationLocale(str string) (string, error) {
+
+	t, err := language.Parse(str)
+
+	if err != nil {
+		return "", fmt.Errorf("Invalid sort locale '%s', %w", str, err)
+	}
+
+	_, idx, confidence := collationMatcher.Match(t)
+
+	if confidence == language.No {
+		return "", fmt.Errorf("Unsupported sort locale '%s'", str)
+	}
+
+	return collationLocales[idx].String(), nil
+}
+
+// localeCollationName returns the name of the SQLite collation used to sort names for 'locale', for example "wof_name_sv".
+func localeCollationName(locale string) string {
+	return fmt.Sprintf("%s_%s", nameCollation, strings.ReplaceAll(locale, "-", "_"))
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCompareLocaleNames(t *testing.T) {
+
+	tests := []struct {
+		Locale   string
+		A        string
+		B        string
+		Expected int
+	}{
+		// Swedish sorts "Ö" as a separate letter after "Z", German sorts it with "O"
+		{Locale: "sv", A: "Örebro", B: "Zagreb", Expected: 1},
+		{Locale: "de", A: "Örebro", B: "Zagreb", Expected: -1},
+		// Spanish sorts "Ñ" after "N", English sorts it with "N"
+		{Locale: "es", A: "Ñuñoa", B: "Nuuk", Expected: 1},
+		{Locale: "en", A: "Ñuñoa", B: "Nuuk", Expected: -1},
+		// Danish sorts "Å" last, after "Æ" and "Ø"
+		{Locale: "da", A: "Aarhus", B: "Zealand", Expected: 1},
+		{Locale: "en", A: "Aarhus", B: "Zealand", Expected: -1},
+	}
+
+	for _, test := range tests {
+
+		v := compareLocaleNames(test.Locale, test.A, test.B)
+
+		if v != test.Expected {
+			t.Errorf("Expected '%s' compared to '%s' in %s to be %d but got %d", test.A, test.B, test.Locale, test.Expected, v)
+		}
+	}
+}
+
+func TestSetSortLocale(t *testing.T) {
+
+	sorts, err := ParseSortOrders("name", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to parse sort orders, %v", err)
+	}
+
+	err = SetSortLocale(sorts, "sv-SE")
+
+	if err != nil {
+		t.Fatalf("Failed to set sort locale, %v", err)
+	}
+
+	if sorts[0].Locale != "sv" {
+		t.Errorf("Expected 'sv-SE' to be matched to 'sv' but got '%s'", sorts[0].Locale)
+	}
+
+	// Locales with variant orders are only used when requested explicitly
+
+	err = SetSortLocale(sorts, "de")
+
+	if err != nil {
+		t.Fatalf("Failed to set sort locale, %v", err)
+	}
+
+	if sorts[0].Locale != "de" {
+		t.Errorf("Expected 'de' to be matched to 'de' but got '%s'", sorts[0].Locale)
+	}
+
+	invalid := map[string]string{
+		"name": "not a locale!",
+		"id":   "sv",
+	}
+
+	for str_sorts, locale := range invalid {
+
+		sorts, err := ParseSortOrders(str_sorts, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to parse sort orders, %v", err)
+		}
+
+		err = SetSortLocale(sorts, locale)
+
+		if err == nil {
+			t.Errorf("Expected locale '%s' to be rejected for '%s'", locale, str_sorts)
+		}
+	}
+}
+
+func TestLocaleNameSort(t *testing.T) {
+
+	// Every record shares the variant name "Testville" so they are equally relevant
+
+	variant := map[string]interface{}{
+		"name:eng_x_variant": []string{"Testville"},
+	}
+
+	features := [][]byte{
+		testFeature(1, "Zagreb", 45.81, 15.98, variant),
+		testFeature(2, "Örebro", 59.27, 15.21, variant),
+		testFeature(3, "Oslo", 59.91, 10.75, variant),
+	}
+
+	ftdb := newTestDatabase(t, "", features...)
+
+	tests := []struct {
+		Sort     string
+		Locale   string
+		Expected []string
+	}{
+		// Sorts applied in SQL
+		{Sort: "name", Locale: "sv", Expected: []string{"3", "1", "2"}},
+		{Sort: "name", Locale: "de", Expected: []string{"2", "3", "1"}},
+		// Sorts applied to the results
+		{Sort: "relevance,name", Locale: "sv", Expected: []string{"3", "1", "2"}},
+		{Sort: "relevance,name", Locale: "de", Expected: []string{"2", "3", "1"}},
+	}
+
+	for _, test := range tests {
+
+		q := url.Values{}
+		q.Set("sort", test.Sort)
+		q.Set("sort_locale", test.Locale)
+
+		sorts, err := NewSortOrdersFromQuery(q)
+
+		if err != nil {
+			t.Fatalf("Failed to parse sort orders, %v", err)
+		}
+
+		opts, err := DefaultQueryOptions()
+
+		if err != nil {
+			t.Fatalf("Failed to create query options, %v", err)
+		}
+
+		opts.Sort = sorts
+
+		ids := queryIds(t, ftdb, "testville", opts)
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected sort '%s' in %s to return %v but got %v", test.Sort, test.Locale, test.Expected, ids)
+		}
+	}
+}
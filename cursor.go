@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/jtacoma/uritemplates"
+	"strconv"
+	"strings"
+)
+
+// The default number of results per page for `CursorOptions`.
+const CURSOR_PER_PAGE int64 = 100
+
+// ErrInvalidCursor is returned when a cursor can not be decoded.
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// CursorOptions implements the pagination.Options interface for cursor-based pagination. The pointer is an opaque string
+// returned by the `Next` method of a previous `CursorResults` instance, or an empty string for the first page.
+type CursorOptions struct {
+	pagination.Options
+	perpage int64
+	cursor  string
+	column  string
+}
+
+// CursorResults implements the pagination.Results interface for cursor-based pagination. The total number of results is not known.
+type CursorResults struct {
+	pagination.Results `json:",omitempty"`
+	PerPageCount       int64  `json:"per_page"`
+	NextCursor         string `json:"next_cursor,omitempty"`
+	PreviousCursor     string `json:"previous_cursor,omitempty"`
+}
+
+// changesCursor is the position of the last record in a page of results ordered by lastmodified date and then ID.
+type changesCursor struct {
+	LastModified int64
+	Id           string
+}
+
+// NewCursorOptions returns a new `CursorOptions` instance for the first page of results.
+func NewCursorOptions() (pagination.Options, error) {
+
+	opts := &CursorOptions{
+		perpage: CURSOR_PER_PAGE,
+		cursor:  "",
+		column:  "lastmodified",
+	}
+
+	return opts, nil
+}
+
+func (opts *CursorOptions) Method() pagination.Method {
+	return pagination.Cursor
+}
+
+func (opts *CursorOptions) PerPage(args ...int64) int64 {
+
+	if len(args) >= 1 {
+		opts.perpage = args[0]
+	}
+
+	return opts.perpage
+}
+
+func (opts *CursorOptions) Pointer(args ...interface{}) interface{} {
+
+	if len(args) >= 1 {
+		opts.cursor = args[0].(string)
+	}
+
+	return opts.cursor
+}
+
+func (opts *CursorOptions) Spill(args ...int64) int64 {
+	return 0
+}
+
+func (opts *CursorOptions) Column(args ...string) string {
+
+	if len(args) >= 1 {
+		opts.column = args[0]
+	}
+
+	return opts.column
+}
+
+func (r *CursorResults) Method() pagination.Method {
+	return pagination.Cursor
+}
+
+func (r *CursorResults) Total() int64 {
+	return -1
+}
+
+func (r *CursorResults) PerPage() int64 {
+	return r.PerPageCount
+}
+
+func (r *CursorResults) Page() int64 {
+	return -1
+}
+
+func (r *CursorResults) Pages() int64 {
+	return -1
+}
+
+func (r *CursorResults) Next() interface{} {
+	return r.NextCursor
+}
+
+func (r *CursorResults) Previous() interface{} {
+	return r.PreviousCursor
+}
+
+// NextURL returns URL to the next set of results in a query response, using the "next" template variable.
+func (r *CursorResults) NextURL(t *uritemplates.UriTemplate) (string, error) {
+	return cursorURL(t, r.NextCursor)
+}
+
+// PreviousURL returns URL to the previous set of results in a query response, using the "next" template variable.
+func (r *CursorResults) PreviousURL(t *uritemplates.UriTemplate) (string, error) {
+	return cursorURL(t, r.PreviousCursor)
+}
+
+func cursorURL(t *uritemplates.UriTemplate, cursor string) (string, error) {
+
+	if cursor == "" {
+		return "#", nil
+	}
+
+	values := map[string]interface{}{
+		"next": cursor,
+	}
+
+	return t.Expand(values)
+}
+
+func (c *changesCursor) String() string {
+	str := fmt.Sprintf("%d:%s", c.LastModified, c.Id)
+	return base64.RawURLEncoding.EncodeToString([]byte(str))
+}
+
+func parseChangesCursor(str string) (*changesCursor, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(str)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(b), ":", 2)
+
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	lastmod, err := strconv.ParseInt(parts[0], 10, 64)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &changesCursor{
+		LastModified: lastmod,
+		Id:           parts[1],
+	}
+
+	return c, nil
+}
@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"time"
+)
+
+// QueryExplanation describes how a query was performed and what it returned.
+type QueryExplanation struct {
+	// The DSN of the database that was queried.
+	Database string `json:"database"`
+	// The search term being queried.
+	Term string `json:"term"`
+	// The SQL statement used to find matching records.
+	SQL string `json:"sql"`
+	// The full-text MATCH expression used by SQL.
+	Match string `json:"match"`
+	// The full-text MATCH expression for the phonetic codes of the search term, if matched phonetically.
+	PhoneticMatch string `json:"phonetic_match,omitempty"`
+	// The output of SQLite's EXPLAIN QUERY PLAN command for SQL.
+	QueryPlan []string `json:"query_plan"`
+	// Descriptions of the filters applied by SQL.
+	SQLFilters []string `json:"sql_filters"`
+	// Descriptions of the filters applied to the results of SQL.
+	GoFilters []string `json:"go_filters"`
+	// The number of rows matched by SQL.
+	RowsMatched int `json:"rows_matched"`
+	// The number of results remaining after filtering.
+	RowsFiltered int `json:"rows_filtered"`
+	// The time spent in each stage of the query, in the order they were performed.
+	Stages []*QueryStage `json:"stages"`
+	// The total time spent performing the query, in nanoseconds.
+	Latency time.Duration `json:"latency"`
+	// The results of the query.
+	Results wof_spr.StandardPlacesResults `json:"results"`
+	// The pagination details for Results, if the query was paginated.
+	Pagination pagination.Results `json:"pagination,omitempty"`
+}
+
+// ExplainQueryString performs a query in the same manner as `QueryStringWithOptions` and returns a `QueryExplanation`
+// describing how it was performed along with its results. Explained queries are never cached. If 'opts' is nil the default
+// query options are used.
+func (ftdb *SQLiteFullTextDatabase) ExplainQueryString(ctx context.Context, term string, opts *QueryOptions, filters ...filter.Filter) (*QueryExplanation, error) {
+
+	if opts == nil {
+
+		default_opts, err := DefaultQueryOptions()
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = default_opts
+	}
+
+	ev := &QueryEvent{
+		Database:   ftdb.db.DSN(),
+		Term:       term,
+		SQLFilters: make([]string, 0),
+		GoFilters:  make([]string, 0),
+	}
+
+	t1 := time.Now()
+
+	ranked, err := ftdb.queryRankedWithEvent(ctx, term, opts, ev, filters...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t_paginate := time.Now()
+
+	page, pg, err := paginateRankedResults(ranked, opts.Pagination)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ev.addStage("paginate", t_paginate)
+
+	t_results := time.Now()
+
+	r, err := resultsFromRankedResults(ctx, ranked, page, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ev.addStage("results", t_results)
+
+	plan, err := ftdb.queryPlan(ctx, ev.SQL, ev.args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive query plan, %w", err)
+	}
+
+	ex := &QueryExplanation{
+		Database:      ev.Database,
+		Term:          ev.Term,
+		SQL:           ev.SQL,
+		Match:         ev.Match,
+		PhoneticMatch: ev.PhoneticMatch,
+		QueryPlan:     plan,
+		SQLFilters:    ev.SQLFilters,
+		GoFilters:     ev.GoFilters,
+		RowsMatched:   ev.RowsMatched,
+		RowsFiltered:  len(ranked),
+		Stages:        ev.Stages,
+		Latency:       time.Since(t1),
+		Results:       r,
+		Pagination:    pg,
+	}
+
+	return ex, nil
+}
+
+// queryPlan returns the output of SQLite's EXPLAIN QUERY PLAN command for 'q' and 'args'. Each row is returned as a string
+// indented to reflect its position in the plan.
+func (ftdb *SQLiteFullTextDatabase) queryPlan(ctx context.Context, q string, args ...interface{}) ([]string, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "EXPLAIN QUERY PLAN "+q, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	plan := make([]string, 0)
+	depth := make(map[int64]int)
+
+	for rows.Next() {
+
+		var id int64
+		var parent int64
+		var notused int64
+		var detail string
+
+		err := rows.Scan(&id, &parent, &notused, &detail)
+
+		if err != nil {
+			return nil, err
+		}
+
+		d := 0
+
+		if parent != 0 {
+			d = depth[parent] + 1
+		}
+
+		depth[id] = d
+
+		indent := ""
+
+		for i := 0; i < d; i++ {
+			indent += "  "
+		}
+
+		plan = append(plan, indent+detail)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
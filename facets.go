@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The names of the facets that can be counted for a query.
+const (
+	PlacetypeFacet     string = "placetype"
+	CountryFacet       string = "country"
+	RepoFacet          string = "repo"
+	IsCurrentFacet     string = "is_current"
+	IsDeprecatedFacet  string = "is_deprecated"
+	IsCeasedFacet      string = "is_ceased"
+	IsSupersededFacet  string = "is_superseded"
+	IsSupersedingFacet string = "is_superseding"
+)
+
+// AllFacets is the list of all the facets that can be counted for a query.
+var AllFacets = []string{
+	PlacetypeFacet,
+	CountryFacet,
+	RepoFacet,
+	IsCurrentFacet,
+	IsDeprecatedFacet,
+	IsCeasedFacet,
+	IsSupersededFacet,
+	IsSupersedingFacet,
+}
+
+// The maximum number of host parameters in a single query. This is SQLite's default limit prior to version 3.32.0.
+const maxQueryParameters int = 999
+
+// FacetCount is the number of results for a query that share the same value for a given facet.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets maps facet names to the counts for each of their values, in descending order of count.
+type Facets map[string][]*FacetCount
+
+// validateFacets ensures that each of 'facets' is listed in `AllFacets` and is only listed once.
+func validateFacets(facets []string) error {
+
+	seen := make(map[string]bool)
+
+	for _, name := range facets {
+
+		if seen[name] {
+			return fmt.Errorf("Duplicate facet '%s'", name)
+		}
+
+		seen[name] = true
+
+		ok := false
+
+		for _, candidate := range AllFacets {
+
+			if name == candidate {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return fmt.Errorf("Invalid facet '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// facetRankedResults returns the counts for each of 'facets' across all of 'ranked'. Counts are derived by aggregating the rows in
+// the spr table of the database each result was retrieved from, so they reflect any filters that have already been applied to 'ranked'.
+func facetRankedResults(ctx context.Context, ranked []*rankedResult, facets []string) (Facets, error) {
+
+	err := validateFacets(facets)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[*SQLiteFullTextDatabase][]interface{})
+	order := make([]*SQLiteFullTextDatabase, 0)
+
+	for _, r := range ranked {
+
+		_, exists := ids[r.source]
+
+		if !exists {
+			order = append(order, r.source)
+		}
+
+		ids[r.source] = append(ids[r.source], r.SPR.Id())
+	}
+
+	counts := make(map[string]map[string]int64)
+
+	for _, name := range facets {
+		counts[name] = make(map[string]int64)
+	}
+
+	// Each facet is a separate query, with its own copy of the IDs, joined by UNION ALL
+	batch_size := maxQueryParameters / len(facets)
+
+	for _, ftdb := range order {
+
+		db_ids := ids[ftdb]
+
+		for start := 0; start < len(db_ids); start += batch_size {
+
+			end := start + batch_size
+
+			if end > len(db_ids) {
+				end = len(db_ids)
+			}
+
+			err := ftdb.countFacets(ctx, facets, db_ids[start:end], counts)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to count facets for %s, %w", ftdb.db.DSN(), err)
+			}
+		}
+	}
+
+	results := make(Facets)
+
+	for _, name := range facets {
+
+		values := make([]*FacetCount, 0)
+
+		for v, c := range counts[name] {
+			values = append(values, &FacetCount{Value: v, Count: c})
+		}
+
+		sort.Slice(values, func(i, j int) bool {
+
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+
+			return values[i].Value < values[j].Value
+		})
+
+		results[name] = values
+	}
+
+	return results, nil
+}
+
+// countFacets adds the counts for each of 'facets' across the (default) spr records for 'ids' to 'counts'.
+func (ftdb *SQLiteFullTextDatabase) countFacets(ctx context.Context, facets []string, ids []interface{}, counts map[string]map[string]int64) error {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+
+	queries := make([]string, len(facets))
+	args := make([]interface{}, 0)
+
+	// Facet names are validated against AllFacets, all of which are also column names in the spr table, so they are safe to interpolate.
+	for idx, name := range facets {
+		queries[idx] = fmt.Sprintf("SELECT '%s', CAST(%s AS TEXT), COUNT(*) FROM %s WHERE alt_label = '' AND id IN (%s) GROUP BY %s", name, name, ftdb.spr_table.Name(), placeholders, name)
+		args = append(args, ids...)
+	}
+
+	q := strings.Join(queries, " UNION ALL ")
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var name string
+		var value sql.NullString
+		var count int64
+
+		err := rows.Scan(&name, &value, &count)
+
+		if err != nil {
+			return err
+		}
+
+		counts[name][value.String] += count
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-flags"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+)
+
+// ResultFilter is implemented by filters that test each result directly, rather than using the `filter.Filter` methods
+// called by `filter.FilterSPR`, for example to test properties that `filter.Filter` has no notion of.
+type ResultFilter interface {
+	filter.Filter
+	// MatchesSPR returns a boolean value indicating whether 's' passes the filter.
+	MatchesSPR(s wof_spr.StandardPlacesResult) bool
+}
+
+// nullFilter implements all of the `filter.Filter` methods, returning true. It is embedded by `ResultFilter` implementations
+// so that they can be passed alongside other filters.
+type nullFilter struct{}
+
+// matchesFilters returns a boolean value indicating whether 's' passes all of 'filters'.
+func matchesFilters(s wof_spr.StandardPlacesResult, filters ...filter.Filter) bool {
+
+	for _, f := range filters {
+
+		r_f, ok := f.(ResultFilter)
+
+		if ok {
+
+			if !r_f.MatchesSPR(s) {
+				return false
+			}
+
+			continue
+		}
+
+		err := filter.FilterSPR(f, s)
+
+		if err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeFilter returns a human-readable description of 'f'.
+func describeFilter(f filter.Filter) string {
+
+	_, is_result := f.(ResultFilter)
+	str_f, is_stringer := f.(fmt.Stringer)
+
+	if is_result && is_stringer {
+		return str_f.String()
+	}
+
+	key, ok := filterCacheKey(f)
+
+	if !ok {
+		return fmt.Sprintf("%T", f)
+	}
+
+	return key
+}
+
+// splitFilters separates 'filters' in to those that can be applied in SQL and those that must be applied to each result.
+// `filter.SPRFilter` instances are applied in SQL unless they have constraints that can not be.
+func splitFilters(filters ...filter.Filter) ([]SQLFilter, []filter.Filter) {
+
+	sql_filters := make([]SQLFilter, 0)
+	go_filters := make([]filter.Filter, 0)
+
+	for _, f := range filters {
+
+		spr_f, is_spr := f.(*filter.SPRFilter)
+
+		if is_spr {
+
+			sql_f, ok := newSPRSQLFilter(spr_f)
+
+			if ok {
+				sql_filters = append(sql_filters, sql_f)
+				continue
+			}
+		}
+
+		sql_f, ok := f.(SQLFilter)
+
+		if ok {
+			sql_filters = append(sql_filters, sql_f)
+		} else {
+			go_filters = append(go_filters, f)
+		}
+	}
+
+	return sql_filters, go_filters
+}
+
+func (f nullFilter) HasPlacetypes(fl flags.PlacetypeFlag) bool {
+	return true
+}
+
+func (f nullFilter) IsCurrent(fl flags.ExistentialFlag) bool {
+	return true
+}
+
+func (f nullFilter) IsDeprecated(fl flags.ExistentialFlag) bool {
+	return true
+}
+
+func (f nullFilter) IsCeased(fl flags.ExistentialFlag) bool {
+	return true
+}
+
+func (f nullFilter) IsSuperseded(fl flags.ExistentialFlag) bool {
+	return true
+}
+
+func (f nullFilter) IsSuperseding(fl flags.ExistentialFlag) bool {
+	return true
+}
+
+func (f nullFilter) IsAlternateGeometry(fl flags.AlternateGeometryFlag) bool {
+	return true
+}
+
+func (f nullFilter) HasAlternateGeometry(fl flags.AlternateGeometryFlag) bool {
+	return true
+}
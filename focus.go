@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/paulmach/orb"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The maximum weight of a `FocusPoint`. Focus can lower a result's relevance by at most this fraction so that a distant
+// result is never ranked below a nearby result that is much less relevant, for example a nearby record whose name only
+// starts with the search term. Combined with the largest importance reduction (see `maxImportanceWeight`) the least relevant
+// result matched by its names keeps a relevance of 0.25 * 0.5 * 0.85 (0.10625), which is still greater than the relevance of
+// results only matched phonetically (see `phoneticScore`).
+const maxFocusWeight float64 = 0.15
+
+// The default distance, in kilometres, at which the focus signal of a result is 0.5.
+const defaultFocusScale float64 = 50.0
+
+// The mean radius of the Earth, in kilometres.
+const earthRadius float64 = 6371.0088
+
+// FocusPoint defines a point, for example the position of a user, that results near it are boosted relative to. Unlike a bounding
+// box it does not exclude any results. A result's focus signal is {SCALE} / ({SCALE} + {DISTANCE}), where distance is measured in
+// kilometres from the focus point to the nearest point of the result's bounding box (or its centroid if it doesn't have one), and
+// its relevance is multiplied by (1.0 - {WEIGHT}) + ({WEIGHT} * {SIGNAL}). Results containing the focus point keep their relevance
+// and the relevance of distant results is lowered by at most the weight, which must not be greater than 0.15.
+type FocusPoint struct {
+	// The point that results are boosted relative to.
+	Point orb.Point
+	// The distance, in kilometres, at which a result's focus signal is 0.5.
+	Scale float64
+	// The weight of the focus signal.
+	Weight float64
+}
+
+// NewFocusPoint returns a new `FocusPoint` instance for 'lat' and 'lon' with the default scale (50 kilometres) and weight (0.15).
+func NewFocusPoint(lat float64, lon float64) (*FocusPoint, error) {
+
+	f := &FocusPoint{
+		Point:  orb.Point{lon, lat},
+		Scale:  defaultFocusScale,
+		Weight: maxFocusWeight,
+	}
+
+	err := f.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ParseFocusPoint returns a new `FocusPoint` instance, with the default scale and weight, for the point defined by 'str_lat' and 'str_lon'.
+func ParseFocusPoint(str_lat string, str_lon string) (*FocusPoint, error) {
+
+	lat, err := strconv.ParseFloat(str_lat, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid focus latitude '%s'", str_lat)
+	}
+
+	lon, err := strconv.ParseFloat(str_lon, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid focus longitude '%s'", str_lon)
+	}
+
+	return NewFocusPoint(lat, lon)
+}
+
+// Validate ensures that the point of 'f' is a valid coordinate, that its scale is greater than zero and that its weight is between
+// 0.0 and 0.15.
+func (f *FocusPoint) Validate() error {
+
+	if f.Point.Lat() < -90.0 || f.Point.Lat() > 90.0 {
+		return fmt.Errorf("Invalid focus latitude %f", f.Point.Lat())
+	}
+
+	if f.Point.Lon() < -180.0 || f.Point.Lon() > 180.0 {
+		return fmt.Errorf("Invalid focus longitude %f", f.Point.Lon())
+	}
+
+	if f.Scale <= 0.0 {
+		return fmt.Errorf("Invalid focus scale %f, scale must be greater than zero", f.Scale)
+	}
+
+	if f.Weight < 0.0 || f.Weight > maxFocusWeight {
+		return fmt.Errorf("Invalid focus weight %f, weight must be between 0.0 and %g", f.Weight, maxFocusWeight)
+	}
+
+	return nil
+}
+
+func (f *FocusPoint) String() string {
+	return fmt.Sprintf("%f,%f,scale=%g,weight=%g", f.Point.Lat(), f.Point.Lon(), f.Scale, f.Weight)
+}
+
+// factor returns the amount the relevance of 'r', whose bounding box is 'bounds', is multiplied by, in the range (1.0 - {WEIGHT}) - 1.0.
+func (f *FocusPoint) factor(r *rankedResult, bounds orb.Bound) float64 {
+
+	d := f.distance(r.SPR.Latitude(), r.SPR.Longitude(), bounds)
+	signal := f.Scale / (f.Scale + d)
+
+	return 1.0 - f.Weight + f.Weight*signal
+}
+
+// distance returns the distance, in kilometres, between the point of 'f' and the nearest point of 'bounds'. If 'bounds' has no area,
+// including when it is missing or crosses the antimeridian, the distance to 'lat', 'lon' is returned.
+func (f *FocusPoint) distance(lat float64, lon float64, bounds orb.Bound) float64 {
+
+	if bounds.IsEmpty() || bounds.Min.Equal(bounds.Max) {
+		return haversineDistance(f.Point.Lat(), f.Point.Lon(), lat, lon)
+	}
+
+	nearest_lat := math.Max(bounds.Min.Lat(), math.Min(bounds.Max.Lat(), f.Point.Lat()))
+	nearest_lon := math.Max(bounds.Min.Lon(), math.Min(bounds.Max.Lon(), f.Point.Lon()))
+
+	return haversineDistance(f.Point.Lat(), f.Point.Lon(), nearest_lat, nearest_lon)
+}
+
+// haversineDistance returns the great-circle distance, in kilometres, between 'lat1', 'lon1' and 'lat2', 'lon2'.
+func haversineDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+
+	rad := math.Pi / 180.0
+
+	d_lat := (lat2 - lat1) * rad
+	d_lon := (lon2 - lon1) * rad
+
+	a := math.Sin(d_lat/2)*math.Sin(d_lat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(d_lon/2)*math.Sin(d_lon/2)
+
+	return 2.0 * earthRadius * math.Asin(math.Min(1.0, math.Sqrt(a)))
+}
+
+// applyFocus multiplies the relevance of each of 'ranked' by its proximity to 'f', reading the bounding box of each result from the
+// spr table.
+func (ftdb *SQLiteFullTextDatabase) applyFocus(ctx context.Context, conn *sql.DB, ranked []*rankedResult, f *FocusPoint) error {
+
+	err := f.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	ids := make([]interface{}, len(ranked))
+
+	for i, r := range ranked {
+		ids[i] = r.SPR.Id()
+	}
+
+	bounds, err := ftdb.retrieveBounds(ctx, conn, ids)
+
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve bounding boxes, %w", err)
+	}
+
+	for _, r := range ranked {
+		r.Score = r.Score * f.factor(r, bounds[r.SPR.Id()])
+	}
+
+	return nil
+}
+
+// retrieveBounds returns the bounding boxes stored in the spr table for 'ids', keyed by ID. The bounding boxes are read from the
+// table rather than the SPRs themselves because the vendored SPR reader assigns the min_longitude and max_latitude columns to
+// each other's fields.
+func (ftdb *SQLiteFullTextDatabase) retrieveBounds(ctx context.Context, conn *sql.DB, ids []interface{}) (map[string]orb.Bound, error) {
+
+	bounds := make(map[string]orb.Bound)
+
+	for start := 0; start < len(ids); start += maxQueryParameters {
+
+		end := start + maxQueryParameters
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		err := ftdb.retrieveBoundsBatch(ctx, conn, ids[start:end], bounds)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bounds, nil
+}
+
+// retrieveBoundsBatch adds the bounding boxes stored in the spr table for 'ids' to 'bounds'.
+func (ftdb *SQLiteFullTextDatabase) retrieveBoundsBatch(ctx context.Context, conn *sql.DB, ids []interface{}, bounds map[string]orb.Bound) error {
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	q := fmt.Sprintf("SELECT id, min_latitude, min_longitude, max_latitude, max_longitude FROM %s WHERE alt_label = '' AND id IN (%s)", ftdb.spr_table.Name(), placeholders)
+
+	rows, err := conn.QueryContext(ctx, q, ids...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var id int64
+		var min_lat float64
+		var min_lon float64
+		var max_lat float64
+		var max_lon float64
+
+		err := rows.Scan(&id, &min_lat, &min_lon, &max_lat, &max_lon)
+
+		if err != nil {
+			return err
+		}
+
+		bounds[strconv.FormatInt(id, 10)] = orb.Bound{
+			Min: orb.Point{min_lon, min_lat},
+			Max: orb.Point{max_lon, max_lat},
+		}
+	}
+
+	return rows.Err()
+}
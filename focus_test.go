@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestFocusWeightBound(t *testing.T) {
+
+	// The least relevant result matched by its names, with the largest importance and focus reductions, must still be
+	// ranked above any result that is only matched phonetically
+
+	weakest := 0.25 * (1.0 - maxImportanceWeight) * (1.0 - maxFocusWeight)
+
+	if weakest <= phoneticScore {
+		t.Errorf("Expected the weakest relevance (%f) to be greater than the phonetic relevance (%f)", weakest, phoneticScore)
+	}
+
+	f, err := NewFocusPoint(45.5, -73.6)
+
+	if err != nil {
+		t.Fatalf("Failed to create focus point, %v", err)
+	}
+
+	f.Weight = maxFocusWeight + 0.05
+
+	err = f.Validate()
+
+	if err == nil {
+		t.Errorf("Expected focus weight %f to be rejected", f.Weight)
+	}
+}
+
+func TestFocusRanking(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(1, "Springfield", 39.80, -89.64, nil),
+		testFeature(2, "Springfield", 42.10, -72.59, nil),
+	}
+
+	ftdb := newTestDatabase(t, "", features...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	focus, err := NewFocusPoint(42.36, -71.06)
+
+	if err != nil {
+		t.Fatalf("Failed to create focus point, %v", err)
+	}
+
+	opts.Focus = focus
+
+	ids := queryIds(t, ftdb, "springfield", opts)
+
+	if !equalIds(ids, []string{"2", "1"}) {
+		t.Errorf("Expected the Springfield nearest the focus point to be ranked first but got %v", ids)
+	}
+}
+
+func TestFocusPhoneticRanking(t *testing.T) {
+
+	features := [][]byte{
+		// Matched by one of its other names, it is not important and it is far from the focus point
+		testFeature(1, "Windy City", -41.29, 174.78, map[string]interface{}{
+			"name:eng_x_variant": []string{"Chicago"},
+			"wof:placetype":      "venue",
+			"mz:is_current":      0,
+		}),
+		// Only matched phonetically, it is important and it contains the focus point
+		testFeature(2, "Shikago", 41.88, -87.63, map[string]interface{}{
+			"wof:placetype":  "country",
+			"wof:population": 100000000,
+			"wof:megacity":   1,
+		}),
+	}
+
+	ftdb := newTestDatabase(t, "phonetic=true", features...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	weights, err := ParseImportanceWeights("population=0.2,megacity=0.1,placetype=0.1,current=0.1")
+
+	if err != nil {
+		t.Fatalf("Failed to parse importance weights, %v", err)
+	}
+
+	focus, err := NewFocusPoint(41.88, -87.63)
+
+	if err != nil {
+		t.Fatalf("Failed to create focus point, %v", err)
+	}
+
+	opts.MatchMode = PhoneticMatch
+	opts.Importance = weights
+	opts.Focus = focus
+
+	ids := queryIds(t, ftdb, "chicago", opts)
+
+	if !equalIds(ids, []string{"1", "2"}) {
+		t.Errorf("Expected the phonetic match to be ranked last but got %v", ids)
+	}
+}
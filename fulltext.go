@@ -2,19 +2,26 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/aaronland/go-pagination"
+	aa_sqlite "github.com/aaronland/go-sqlite"
+	aa_database "github.com/aaronland/go-sqlite/database"
+	"github.com/whosonfirst/go-whosonfirst-feature/alt"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
 	"github.com/whosonfirst/go-whosonfirst-search/filter"
 	"github.com/whosonfirst/go-whosonfirst-search/fulltext"
 	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
-	aa_sqlite "github.com/aaronland/go-sqlite"
 	"github.com/whosonfirst/go-whosonfirst-sqlite-features/tables"
 	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
-	aa_database "github.com/aaronland/go-sqlite/database"
-	_ "log"
+	"log"
 	"net/url"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type SQLiteFullTextDatabase struct {
@@ -22,7 +29,29 @@ type SQLiteFullTextDatabase struct {
 	db           *aa_database.SQLiteDatabase
 	spr_table    aa_sqlite.Table
 	search_table aa_sqlite.Table
-	mu           *sync.RWMutex
+	// The rtree and geojson tables are not created or indexed by this package but are used for point-in-polygon queries if present.
+	rtree_table   aa_sqlite.Table
+	geojson_table aa_sqlite.Table
+	// The ancestors and properties tables are not created or indexed by this package but are used to derive labels if present.
+	ancestors_table  aa_sqlite.Table
+	properties_table aa_sqlite.Table
+	mu               *sync.RWMutex
+	cache            *queryCache
+	observers        []Observer
+	// The synonyms used to expand search terms or nil if they are not expanded.
+	synonyms *SynonymDictionary
+	// Whether synonyms are added to the names of each record when it is indexed.
+	index_synonyms bool
+	// Whether the Latin transliterations of names written in other scripts are indexed, and added to search terms.
+	transliterate          bool
+	transliterations_table aa_sqlite.Table
+	// Whether the normalized forms of names, without diacritics, punctuation or ordinal suffixes, are indexed and added to search terms.
+	normalize bool
+	// Whether runs of Chinese, Japanese and Korean characters are indexed, and searched for, as bigrams.
+	cjk_bigrams bool
+	// Whether the phonetic codes of names are indexed, so that search terms can be matched phonetically.
+	phonetic        bool
+	phonetics_table aa_sqlite.Table
 }
 
 func init() {
@@ -30,6 +59,26 @@ func init() {
 	fulltext.RegisterFullTextDatabase(ctx, "sqlite", NewSQLiteFullTextDatabase)
 }
 
+// NewSQLiteFullTextDatabase returns a new `SQLiteFullTextDatabase` instance configured by 'str_uri' which is expected to take the form of:
+//
+//	sqlite://?dsn={DSN}
+//
+// Optional query parameters are:
+// * `cache_size` The maximum number of query results to cache. Default is 0 (no caching).
+// * `cache_ttl` The number of seconds a cached query result remains valid. Default is 0 (cached results remain valid until the cache is invalidated).
+// * `slow_query_threshold` If present, log queries and index operations that take longer than this many milliseconds to the default logger.
+// * `synonyms` The synonym dictionary used to expand (plain text) search terms. Valid options are "default" (the built-in English and
+// French dictionary), "none" or the path to a file (see `NewSynonymDictionaryFromReader`). May be repeated, in which case the dictionaries
+// are merged. Default is "none" (search terms are not expanded).
+// * `synonyms_index` If true, add synonyms to the names of each record when it is indexed. Default is false.
+// * `transliterate` If true, index the Latin transliterations of names written in other scripts (see `Transliterate`), recording
+// them in a `transliterations` table, and transliterate search terms. Default is false.
+// * `normalize` If true, index the normalized forms of names (see `NormalizeName`), without diacritics and with their hyphens, apostrophes,
+// periods, ampersands and numeric ordinals normalized, and add the normalized forms of search terms. Default is false.
+// * `cjk_bigrams` If true, index runs of Chinese, Japanese and Korean characters as overlapping pairs of characters (bigrams) so that
+// they can be matched by partial search terms (see `cjkIndexedForms`). Default is false.
+// * `phonetic` If true, index the phonetic codes (see `Metaphone`) of the Latin names of each record, including transliterations, in a
+// `phonetics` table so that they can be queried using `PhoneticMatch`. Default is false.
 func NewSQLiteFullTextDatabase(ctx context.Context, str_uri string) (fulltext.FullTextDatabase, error) {
 
 	u, err := url.Parse(str_uri)
@@ -46,7 +95,7 @@ func NewSQLiteFullTextDatabase(ctx context.Context, str_uri string) (fulltext.Fu
 		return nil, errors.New("Missing 'dsn' parameter")
 	}
 
-	sqlite_db, err := aa_database.NewDB(ctx, dsn)
+	sqlite_db, err := aa_database.NewDBWithDriver(ctx, sqliteDriver, dsn)
 
 	if err != nil {
 		return nil, err
@@ -64,13 +113,178 @@ func NewSQLiteFullTextDatabase(ctx context.Context, str_uri string) (fulltext.Fu
 		return nil, err
 	}
 
+	rtree_table, err := tables.NewRTreeTable(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	geojson_table, err := tables.NewGeoJSONTable(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors_table, err := tables.NewAncestorsTable(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	properties_table, err := tables.NewPropertiesTable(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
 	mu := new(sync.RWMutex)
 
 	ftdb := &SQLiteFullTextDatabase{
-		db:           sqlite_db,
-		search_table: search_table,
-		spr_table:    spr_table,
-		mu:           mu,
+		db:               sqlite_db,
+		search_table:     search_table,
+		spr_table:        spr_table,
+		rtree_table:      rtree_table,
+		geojson_table:    geojson_table,
+		ancestors_table:  ancestors_table,
+		properties_table: properties_table,
+		mu:               mu,
+	}
+
+	str_size := q.Get("cache_size")
+
+	if str_size != "" {
+
+		size, err := strconv.Atoi(str_size)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'cache_size' parameter, %w", err)
+		}
+
+		var ttl time.Duration
+
+		str_ttl := q.Get("cache_ttl")
+
+		if str_ttl != "" {
+
+			secs, err := strconv.Atoi(str_ttl)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid 'cache_ttl' parameter, %w", err)
+			}
+
+			ttl = time.Duration(secs) * time.Second
+		}
+
+		if size > 0 {
+			ftdb.cache = newQueryCache(size, ttl)
+		}
+	}
+
+	synonyms, err := loadSynonymDictionary(q["synonyms"])
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid 'synonyms' parameter, %w", err)
+	}
+
+	ftdb.synonyms = synonyms
+
+	str_index_synonyms := q.Get("synonyms_index")
+
+	if str_index_synonyms != "" {
+
+		index_synonyms, err := strconv.ParseBool(str_index_synonyms)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'synonyms_index' parameter, %w", err)
+		}
+
+		ftdb.index_synonyms = index_synonyms && synonyms != nil
+	}
+
+	str_transliterate := q.Get("transliterate")
+
+	if str_transliterate != "" {
+
+		transliterate, err := strconv.ParseBool(str_transliterate)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'transliterate' parameter, %w", err)
+		}
+
+		ftdb.transliterate = transliterate
+	}
+
+	if ftdb.transliterate {
+		ftdb.transliterations_table, err = newTransliterationsTableWithDatabase(ctx, sqlite_db)
+	} else {
+		ftdb.transliterations_table, err = newTransliterationsTable(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	str_normalize := q.Get("normalize")
+
+	if str_normalize != "" {
+
+		normalize, err := strconv.ParseBool(str_normalize)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'normalize' parameter, %w", err)
+		}
+
+		ftdb.normalize = normalize
+	}
+
+	str_cjk := q.Get("cjk_bigrams")
+
+	if str_cjk != "" {
+
+		cjk_bigrams, err := strconv.ParseBool(str_cjk)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'cjk_bigrams' parameter, %w", err)
+		}
+
+		ftdb.cjk_bigrams = cjk_bigrams
+	}
+
+	str_phonetic := q.Get("phonetic")
+
+	if str_phonetic != "" {
+
+		phonetic, err := strconv.ParseBool(str_phonetic)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'phonetic' parameter, %w", err)
+		}
+
+		ftdb.phonetic = phonetic
+	}
+
+	if ftdb.phonetic {
+		ftdb.phonetics_table, err = newPhoneticsTableWithDatabase(ctx, sqlite_db)
+	} else {
+		ftdb.phonetics_table, err = newPhoneticsTable(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	str_threshold := q.Get("slow_query_threshold")
+
+	if str_threshold != "" {
+
+		ms, err := strconv.Atoi(str_threshold)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid 'slow_query_threshold' parameter, %w", err)
+		}
+
+		threshold := time.Duration(ms) * time.Millisecond
+		ftdb.AddObserver(NewSlowQueryLogger(log.Default(), threshold))
 	}
 
 	return ftdb, nil
@@ -80,11 +294,41 @@ func (ftdb *SQLiteFullTextDatabase) Close(ctx context.Context) error {
 	return ftdb.db.Close()
 }
 
+// AddObserver registers 'o' to receive events for each query and index operation. It should be called before the database is used.
+func (ftdb *SQLiteFullTextDatabase) AddObserver(o Observer) {
+	ftdb.observers = append(ftdb.observers, o)
+}
+
 func (ftdb *SQLiteFullTextDatabase) IndexFeature(ctx context.Context, f []byte) error {
 
 	ftdb.mu.Lock()
 	defer ftdb.mu.Unlock()
 
+	t1 := time.Now()
+
+	err := ftdb.indexFeature(ctx, f)
+
+	if len(ftdb.observers) > 0 {
+
+		id, _ := properties.Id(f)
+
+		ev := &IndexEvent{
+			Database: ftdb.db.DSN(),
+			Id:       id,
+			Latency:  time.Since(t1),
+			Error:    err,
+		}
+
+		for _, o := range ftdb.observers {
+			o.ObserveIndex(ctx, ev)
+		}
+	}
+
+	return err
+}
+
+func (ftdb *SQLiteFullTextDatabase) indexFeature(ctx context.Context, f []byte) error {
+
 	err := ftdb.search_table.IndexRecord(ctx, ftdb.db, f)
 
 	if err != nil {
@@ -97,27 +341,324 @@ func (ftdb *SQLiteFullTextDatabase) IndexFeature(ctx context.Context, f []byte)
 		return err
 	}
 
+	if ftdb.transliterate || ftdb.normalize || ftdb.cjk_bigrams || ftdb.index_synonyms || ftdb.phonetic {
+
+		err = ftdb.indexNameForms(ctx, f)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	ftdb.invalidateCache()
+	return nil
+}
+
+// indexNameForms adds alternate forms of the names of 'f', its transliterations, normalized forms, CJK bigrams and synonyms, to the names indexed in the search
+// table and records the phonetic codes of the result. Alternate geometries are not indexed in the search table so they are ignored.
+func (ftdb *SQLiteFullTextDatabase) indexNameForms(ctx context.Context, f []byte) error {
+
+	if alt.IsAlt(f) {
+		return nil
+	}
+
+	id, err := properties.Id(f)
+
+	if err != nil {
+		return err
+	}
+
+	// Transliterations are added first so that synonyms are added for them too
+
+	if ftdb.transliterate {
+
+		err = ftdb.indexTransliterations(ctx, id, f)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add transliterations for %d, %w", id, err)
+		}
+	}
+
+	if ftdb.normalize {
+
+		err = ftdb.indexNormalizedNames(ctx, id, f)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add normalized names for %d, %w", id, err)
+		}
+	}
+
+	if ftdb.cjk_bigrams {
+
+		err = ftdb.appendIndexedNames(ctx, id, cjkIndexedForms)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add CJK bigrams for %d, %w", id, err)
+		}
+	}
+
+	if ftdb.index_synonyms {
+
+		err = ftdb.appendIndexedNames(ctx, id, ftdb.synonyms.expandNames)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add synonyms for %d, %w", id, err)
+		}
+	}
+
+	// Phonetic codes are added last so that they include every other form
+
+	if ftdb.phonetic {
+
+		err = ftdb.indexPhonetics(ctx, id)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add phonetic codes for %d, %w", id, err)
+		}
+	}
+
 	return nil
 }
 
+// CacheStats returns the hit and miss counts for the query cache. The second return value is false if caching is not enabled.
+func (ftdb *SQLiteFullTextDatabase) CacheStats() (CacheStats, bool) {
+
+	if ftdb.cache == nil {
+		return CacheStats{}, false
+	}
+
+	return ftdb.cache.Stats(), true
+}
+
+// invalidateCache removes all the entries in the query cache. It should be called after any operation that writes to the database.
+func (ftdb *SQLiteFullTextDatabase) invalidateCache() {
+
+	if ftdb.cache != nil {
+		ftdb.cache.Purge()
+	}
+}
+
+// QueryString returns the results matching 'term' and 'filters' using the default query options, in descending order of relevance.
+// Equally relevant results are returned in the order they are matched by the full-text query.
 func (ftdb *SQLiteFullTextDatabase) QueryString(ctx context.Context, term string, filters ...filter.Filter) (wof_spr.StandardPlacesResults, error) {
 
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, _, err := ftdb.QueryStringWithOptions(ctx, term, opts, filters...)
+	return r, err
+}
+
+// QueryStringWithOptions returns the results matching 'term' and 'filters', ranked by relevance and paginated according to 'opts'.
+// If 'opts' is nil the default query options are used.
+func (ftdb *SQLiteFullTextDatabase) QueryStringWithOptions(ctx context.Context, term string, opts *QueryOptions, filters ...filter.Filter) (wof_spr.StandardPlacesResults, pagination.Results, error) {
+
+	if opts == nil {
+
+		default_opts, err := DefaultQueryOptions()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		opts = default_opts
+	}
+
+	var cache_key string
+	var cache_gen int64
+	var use_cache bool
+
+	if ftdb.cache != nil {
+
+		t1 := time.Now()
+
+		cache_key, use_cache = queryCacheKey(term, opts, filters...)
+
+		if use_cache {
+
+			r, pg, ok := ftdb.cache.Get(cache_key)
+
+			if ok {
+
+				ev := &QueryEvent{
+					Database: ftdb.db.DSN(),
+					Term:     term,
+					Results:  len(r.Results()),
+					Cached:   true,
+				}
+
+				if pg != nil {
+					ev.Results = int(pg.Total())
+				}
+
+				ev.Latency = time.Since(t1)
+
+				for _, o := range ftdb.observers {
+					o.ObserveQuery(ctx, ev)
+				}
+
+				return r, pg, nil
+			}
+
+			cache_gen = ftdb.cache.Generation()
+		}
+	}
+
+	ranked, err := ftdb.queryRanked(ctx, term, opts, filters...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page, pg, err := paginateRankedResults(ranked, opts.Pagination)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := resultsFromRankedResults(ctx, ranked, page, opts)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if use_cache {
+		ftdb.cache.Set(cache_key, cache_gen, r, pg)
+	}
+
+	return r, pg, nil
+}
+
+// queryRanked returns all the results matching 'term' and 'filters' with their relevance scores, in descending order of relevance.
+func (ftdb *SQLiteFullTextDatabase) queryRanked(ctx context.Context, term string, opts *QueryOptions, filters ...filter.Filter) ([]*rankedResult, error) {
+
+	ev := &QueryEvent{
+		Database: ftdb.db.DSN(),
+		Term:     term,
+	}
+
+	t1 := time.Now()
+
+	ranked, err := ftdb.queryRankedWithEvent(ctx, term, opts, ev, filters...)
+
+	ev.Latency = time.Since(t1)
+	ev.Results = len(ranked)
+	ev.Error = err
+
+	for _, o := range ftdb.observers {
+		o.ObserveQuery(ctx, ev)
+	}
+
+	return ranked, err
+}
+
+// queryRankedWithEvent does the work of queryRanked recording the details of the query in 'ev'.
+func (ftdb *SQLiteFullTextDatabase) queryRankedWithEvent(ctx context.Context, term string, opts *QueryOptions, ev *QueryEvent, filters ...filter.Filter) ([]*rankedResult, error) {
+
 	conn, err := ftdb.db.Conn()
 
 	if err != nil {
 		return nil, err
 	}
 
-	q := fmt.Sprintf("SELECT id FROM %s WHERE names_all MATCH ? OR id MATCH ?", ftdb.search_table.Name())
+	match, err := matchExpression(term, opts.MatchMode, ftdb.tokenExpanders(opts)...)
+
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := conn.QueryContext(ctx, q, term, term)
+	err = validateSortOrders(opts.Sort)
 
 	if err != nil {
 		return nil, err
 	}
 
+	q := fmt.Sprintf("SELECT id FROM %s WHERE names_all MATCH ? OR id MATCH ?", ftdb.search_table.Name())
+	match_q := fmt.Sprintf("SELECT CAST(id AS TEXT) FROM %s WHERE names_all MATCH ? OR id MATCH ?", ftdb.search_table.Name())
+	args := []interface{}{match, match}
+
+	// Records matched phonetically are added to those matched by their names, they are told apart once the query has been performed
+
+	phonetic_match := ""
+
+	if opts.MatchMode == PhoneticMatch {
+
+		err := ftdb.requireTables(ctx, conn, "phonetic matching", ftdb.phonetics_table)
+
+		if err != nil {
+			return nil, err
+		}
+
+		phonetic_match = phoneticExpression(term)
+	}
+
+	if phonetic_match != "" {
+		q = fmt.Sprintf("%s UNION SELECT docid FROM %s WHERE codes MATCH ?", q, ftdb.phonetics_table.Name())
+		match_q = fmt.Sprintf("%s UNION SELECT CAST(docid AS TEXT) FROM %s WHERE codes MATCH ?", match_q, ftdb.phonetics_table.Name())
+		args = append(args, phonetic_match)
+	}
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	where := make([]string, 0)
+
+	for _, f := range sql_filters {
+
+		clause, clause_args := f.WhereSQL("s")
+
+		if clause == "" {
+			continue
+		}
+
+		where = append(where, clause)
+		args = append(args, clause_args...)
+
+		ev.SQLFilters = append(ev.SQLFilters, describeFilter(f))
+	}
+
+	// Results that are not sorted by relevance are sorted in SQL, in which case the matching records are
+	// selected from the spr table rather than it only being used to apply filters.
+
+	sort_sql := len(opts.Sort) > 0 && sortInSQL(opts.Sort)
+
+	switch {
+	case sort_sql:
+
+		where = append([]string{"s.alt_label = ''", fmt.Sprintf("s.id IN (%s)", match_q)}, where...)
+
+		order_by, order_args := orderBySQL("s", opts.Sort)
+
+		q = fmt.Sprintf("SELECT s.id FROM %s s WHERE %s ORDER BY %s", ftdb.spr_table.Name(), strings.Join(where, " AND "), order_by)
+		args = append(args, order_args...)
+
+	case len(where) > 0:
+		q = fmt.Sprintf("SELECT m.id FROM (%s) AS m WHERE EXISTS (SELECT 1 FROM %s s WHERE s.id = CAST(m.id AS TEXT) AND s.alt_label = '' AND %s)", q, ftdb.spr_table.Name(), strings.Join(where, " AND "))
+	}
+
+	ev.SQL = q
+	ev.Match = match
+	ev.PhoneticMatch = phonetic_match
+	ev.args = args
+
+	for _, f := range go_filters {
+		ev.GoFilters = append(ev.GoFilters, describeFilter(f))
+	}
+
+	t_match := time.Now()
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return nil, wrapMatchError(term, err)
+	}
+
 	defer rows.Close()
 
+	ev.addStage("match", t_match)
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -135,6 +676,8 @@ func (ftdb *SQLiteFullTextDatabase) QueryString(ctx context.Context, term string
 	remaining := 0
 	idx := 0
 
+	t_fetch := time.Now()
+
 	for rows.Next() {
 
 		var id int64
@@ -177,6 +720,12 @@ func (ftdb *SQLiteFullTextDatabase) QueryString(ctx context.Context, term string
 		idx += 1
 	}
 
+	err = rows.Err()
+
+	if err != nil {
+		return nil, wrapMatchError(term, err)
+	}
+
 	for remaining > 0 {
 		select {
 		case <-done_ch:
@@ -188,6 +737,27 @@ func (ftdb *SQLiteFullTextDatabase) QueryString(ctx context.Context, term string
 		}
 	}
 
+	ev.RowsMatched = idx
+	ev.SPRFetchTime = time.Since(t_fetch)
+	ev.addStage("fetch", t_fetch)
+
+	var names_matches map[int64]bool
+
+	if phonetic_match != "" {
+
+		t_phonetic := time.Now()
+
+		names_matches, err = ftdb.namesMatches(ctx, conn, match)
+
+		if err != nil {
+			return nil, wrapMatchError(term, err)
+		}
+
+		ev.addStage("phonetic", t_phonetic)
+	}
+
+	t_filter := time.Now()
+
 	indices := make([]int, 0)
 
 	for i, _ := range spr_results {
@@ -196,15 +766,258 @@ func (ftdb *SQLiteFullTextDatabase) QueryString(ctx context.Context, term string
 
 	sort.Ints(indices)
 
-	sorted := make([]wof_spr.StandardPlacesResult, len(indices))
+	ranked := make([]*rankedResult, 0)
+
+	for _, i := range indices {
+
+		spr_r := spr_results[i]
+
+		if !matchesFilters(spr_r, go_filters...) {
+			continue
+		}
+
+		r := &rankedResult{
+			SPR:    spr_r,
+			Score:  relevance(term, spr_r),
+			Index:  i,
+			source: ftdb,
+		}
+
+		if names_matches != nil {
 
-	for idx, i := range indices {
-		sorted[idx] = spr_results[i]
+			id, err := strconv.ParseInt(spr_r.Id(), 10, 64)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !names_matches[id] {
+				r.Score = phoneticScore
+				r.phonetic = true
+			}
+		}
+
+		ranked = append(ranked, r)
 	}
 
-	r := &spr.SQLiteResults{
-		Places: sorted,
+	ev.addStage("filter", t_filter)
+
+	// Records matched by splitting the search term in to a place and its qualifiers are added to those matched by the whole term
+
+	qualified := 0
+
+	if opts.Hierarchical && opts.MatchMode != RawMatch {
+
+		t_qualified := time.Now()
+
+		exclude := make(map[string]bool)
+
+		for _, r := range ranked {
+			exclude[r.SPR.Id()] = true
+		}
+
+		qualified_ranked, err := ftdb.qualifiedMatches(ctx, conn, term, opts, exclude, filters...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range qualified_ranked {
+			r.Index = len(ranked)
+			ranked = append(ranked, r)
+		}
+
+		qualified = len(qualified_ranked)
+		ev.addStage("qualified", t_qualified)
 	}
 
-	return r, nil
+	if opts.Importance != nil {
+
+		t_importance := time.Now()
+
+		err := applyImportance(ctx, ranked, opts.Importance)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ev.addStage("importance", t_importance)
+	}
+
+	if opts.Focus != nil {
+
+		t_focus := time.Now()
+
+		err := ftdb.applyFocus(ctx, conn, ranked, opts.Focus)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ev.addStage("focus", t_focus)
+	}
+
+	// Property values are needed to sort results in Go, including when they are merged with the results from other databases
+
+	sort_properties := sortPropertyPaths(opts.Sort)
+
+	if len(sort_properties) > 0 {
+
+		t_properties := time.Now()
+
+		err := loadRankedProperties(ctx, ranked, sort_properties)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ev.addStage("properties", t_properties)
+	}
+
+	// Results sorted in SQL are already in order, unless records matched by qualifiers were added
+
+	if !sort_sql || qualified > 0 {
+
+		t_sort := time.Now()
+
+		sortRankedResults(ranked, opts.Sort...)
+
+		ev.addStage("sort", t_sort)
+	}
+
+	return ranked, nil
+}
+
+// appendIndexedNames appends the names returned by 'expand', for the current value of the `names_all` column of the search table
+// for 'id', to that column. It is used to add alternate forms of a record's names, for example synonyms, after it has been indexed.
+func (ftdb *SQLiteFullTextDatabase) appendIndexedNames(ctx context.Context, id int64, expand func(names string) []string) error {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return err
+	}
+
+	rowid, names, found, err := ftdb.indexedNames(ctx, conn, id)
+
+	if err != nil {
+		return err
+	}
+
+	// Alternate geometries are not indexed in the search table
+	if !found {
+		return nil
+	}
+
+	expanded := expand(names)
+
+	if len(expanded) == 0 {
+		return nil
+	}
+
+	update_q := fmt.Sprintf("UPDATE %s SET names_all = ? WHERE rowid = ?", ftdb.search_table.Name())
+
+	_, err = conn.ExecContext(ctx, update_q, names+" "+strings.Join(expanded, " "), rowid)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// indexedNames returns the rowid and the current value of the `names_all` column of the search table for 'id'. The third return
+// value is false if 'id' is not in the search table.
+func (ftdb *SQLiteFullTextDatabase) indexedNames(ctx context.Context, conn *sql.DB, id int64) (int64, string, bool, error) {
+
+	// The id column is matched, rather than compared, since it is not the rowid of the (fts4) search table
+	q := fmt.Sprintf("SELECT rowid, id, names_all FROM %s WHERE id MATCH ?", ftdb.search_table.Name())
+
+	rows, err := conn.QueryContext(ctx, q, fmt.Sprintf(`"%d"`, id))
+
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var rowid int64
+		var row_id int64
+		var row_names sql.NullString
+
+		err := rows.Scan(&rowid, &row_id, &row_names)
+
+		if err != nil {
+			return 0, "", false, err
+		}
+
+		if row_id == id {
+			return rowid, row_names.String, true, nil
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	return 0, "", false, nil
+}
+
+// tokenExpanders returns the functions used to add alternatives for each token of a plain text search term according to 'opts', which may be nil.
+func (ftdb *SQLiteFullTextDatabase) tokenExpanders(opts *QueryOptions) []tokenExpander {
+
+	expanders := make([]tokenExpander, 0)
+
+	if ftdb.synonyms != nil {
+
+		var languages []string
+
+		if opts != nil {
+			languages = opts.SynonymLanguages
+		}
+
+		expanders = append(expanders, ftdb.synonyms.expander(languages))
+	}
+
+	if ftdb.transliterate {
+		expanders = append(expanders, transliterationExpander())
+	}
+
+	if ftdb.normalize {
+		expanders = append(expanders, normalizationExpander())
+	}
+
+	if ftdb.cjk_bigrams {
+		expanders = append(expanders, cjkExpander())
+	}
+
+	return expanders
+}
+
+// hasTable returns a boolean value indicating whether the table 't' exists in the database.
+func (ftdb *SQLiteFullTextDatabase) hasTable(ctx context.Context, conn *sql.DB, t aa_sqlite.Table) (bool, error) {
+	return aa_sqlite.HasTableWithSQLDB(ctx, conn, t.Name())
+}
+
+// requireTables returns an error if any of 'tables' are missing from the database. 'purpose' is used to describe why the tables are needed.
+func (ftdb *SQLiteFullTextDatabase) requireTables(ctx context.Context, conn *sql.DB, purpose string, tables ...aa_sqlite.Table) error {
+
+	for _, t := range tables {
+
+		has_table, err := ftdb.hasTable(ctx, conn, t)
+
+		if err != nil {
+			return err
+		}
+
+		if !has_table {
+			return fmt.Errorf("Database is missing '%s' table, which is necessary for %s", t.Name(), purpose)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-features/tables"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testFeature returns a Who's On First locality named 'name' at 'lat', 'lon' encoded as GeoJSON. 'props' are added to, or replace,
+// the default properties.
+func testFeature(id int64, name string, lat float64, lon float64, props map[string]interface{}) []byte {
+
+	properties := map[string]interface{}{
+		"wof:id":           id,
+		"wof:name":         name,
+		"wof:placetype":    "locality",
+		"wof:parent_id":    -1,
+		"wof:country":      "XX",
+		"wof:repo":         "whosonfirst-data-test",
+		"wof:lastmodified": 1600000000,
+		"wof:belongsto":    []int64{},
+		"mz:is_current":    1,
+		"geom:latitude":    lat,
+		"geom:longitude":   lon,
+	}
+
+	for k, v := range props {
+		properties[k] = v
+	}
+
+	f := map[string]interface{}{
+		"type":       "Feature",
+		"id":         id,
+		"properties": properties,
+		"geometry": map[string]interface{}{
+			"type":        "Point",
+			"coordinates": []float64{lon, lat},
+		},
+	}
+
+	enc, err := json.Marshal(f)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return enc
+}
+
+// newTestDatabase returns a new `SQLiteFullTextDatabase` instance, created in a temporary directory with the query parameters
+// 'params', that has indexed 'features'. Features are also indexed in the properties table.
+func newTestDatabase(t *testing.T, params string, features ...[]byte) *SQLiteFullTextDatabase {
+
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	return newTestDatabaseWithDSN(t, dsn, params, features...)
+}
+
+// newTestDatabaseWithDSN returns a new `SQLiteFullTextDatabase` instance for 'dsn', with the query parameters 'params', that has indexed
+// 'features'. Features are also indexed in the properties table.
+func newTestDatabaseWithDSN(t *testing.T, dsn string, params string, features ...[]byte) *SQLiteFullTextDatabase {
+
+	t.Helper()
+
+	ctx := context.Background()
+
+	uri := fmt.Sprintf("sqlite://?dsn=%s", dsn)
+
+	if params != "" {
+		uri = fmt.Sprintf("%s&%s", uri, params)
+	}
+
+	db, err := NewSQLiteFullTextDatabase(ctx, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create database for %s, %v", uri, err)
+	}
+
+	ftdb := db.(*SQLiteFullTextDatabase)
+
+	t.Cleanup(func() {
+		ftdb.Close(ctx)
+	})
+
+	properties_table, err := tables.NewPropertiesTableWithDatabase(ctx, ftdb.db)
+
+	if err != nil {
+		t.Fatalf("Failed to create properties table, %v", err)
+	}
+
+	for _, f := range features {
+
+		err := ftdb.IndexFeature(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to index feature, %v", err)
+		}
+
+		err = properties_table.IndexRecord(ctx, ftdb.db, f)
+
+		if err != nil {
+			t.Fatalf("Failed to index properties, %v", err)
+		}
+	}
+
+	return ftdb
+}
+
+// indexAncestors creates the ancestors table in 'ftdb', if necessary, and indexes the hierarchies (`wof:hierarchy`) of 'features' in it.
+func indexAncestors(t *testing.T, ftdb *SQLiteFullTextDatabase, features ...[]byte) {
+
+	t.Helper()
+
+	ctx := context.Background()
+
+	ancestors_table, err := tables.NewAncestorsTableWithDatabase(ctx, ftdb.db)
+
+	if err != nil {
+		t.Fatalf("Failed to create ancestors table, %v", err)
+	}
+
+	for _, f := range features {
+
+		err := ancestors_table.IndexRecord(ctx, ftdb.db, f)
+
+		if err != nil {
+			t.Fatalf("Failed to index ancestors, %v", err)
+		}
+	}
+}
+
+// queryIds returns the IDs of the results for 'term', in the order they are returned, using 'opts' or, if nil, the default query options.
+func queryIds(t *testing.T, ftdb *SQLiteFullTextDatabase, term string, opts *QueryOptions) []string {
+
+	t.Helper()
+
+	r, _, err := ftdb.QueryStringWithOptions(context.Background(), term, opts)
+
+	if err != nil {
+		t.Fatalf("Failed to query '%s', %v", term, err)
+	}
+
+	ids := make([]string, 0)
+
+	for _, s := range r.Results() {
+		ids = append(ids, s.Id())
+	}
+
+	return ids
+}
+
+// sortedIds returns a sorted copy of 'ids', for comparing results whose order is not significant.
+func sortedIds(ids []string) []string {
+
+	sorted := append([]string{}, ids...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+// equalIds returns a boolean value indicating whether 'a' and 'b' contain the same IDs in the same order.
+func equalIds(a []string, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestNilQueryOptions(t *testing.T) {
+
+	ctx := context.Background()
+
+	ftdb := newTestDatabase(t, "cache_size=10", testFeature(1, "Paris", 48.86, 2.35, nil))
+
+	r, _, err := ftdb.QueryStringWithOptions(ctx, "paris", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to query with nil options, %v", err)
+	}
+
+	if len(r.Results()) != 1 {
+		t.Errorf("Expected 1 result with nil options but got %d", len(r.Results()))
+	}
+
+	_, err = ftdb.ExplainQueryString(ctx, "paris", nil)
+
+	if err != nil {
+		t.Errorf("Failed to explain query with nil options, %v", err)
+	}
+
+	q, err := ParseStructuredQuery("locality=paris")
+
+	if err != nil {
+		t.Fatalf("Failed to parse structured query, %v", err)
+	}
+
+	r, _, err = ftdb.QueryStructured(ctx, q, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to perform structured query with nil options, %v", err)
+	}
+
+	if len(r.Results()) != 1 {
+		t.Errorf("Expected 1 structured result with nil options but got %d", len(r.Results()))
+	}
+}
@@ -0,0 +1,223 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"strconv"
+	"strings"
+)
+
+// GeometryMode defines the geometry included with each feature when results are returned as a GeoJSON FeatureCollection.
+type GeometryMode uint8
+
+const (
+	// FullGeometry includes each record's original geometry.
+	FullGeometry GeometryMode = iota
+	// BBoxGeometry includes a polygon for the bounding box of each record's geometry.
+	BBoxGeometry
+	// PointGeometry includes a point for each record's centroid.
+	PointGeometry
+)
+
+// FeatureOptions defines options for the `FeatureCollection` method of `SearchResults`.
+type FeatureOptions struct {
+	// The geometry included with each feature. Smaller geometries keep payloads small.
+	Geometry GeometryMode
+}
+
+// DefaultFeatureOptions returns a new FeatureOptions instance that will include each record's original geometry.
+func DefaultFeatureOptions() *FeatureOptions {
+
+	opts := &FeatureOptions{
+		Geometry: FullGeometry,
+	}
+
+	return opts
+}
+
+// ParseGeometryMode returns the `GeometryMode` for 'str' which is expected to be "full", "bbox" or "point".
+func ParseGeometryMode(str string) (GeometryMode, error) {
+
+	switch strings.ToLower(str) {
+	case "full", "":
+		return FullGeometry, nil
+	case "bbox":
+		return BBoxGeometry, nil
+	case "point":
+		return PointGeometry, nil
+	default:
+		return 0, fmt.Errorf("Invalid geometry mode '%s'", str)
+	}
+}
+
+func (m GeometryMode) String() string {
+
+	switch m {
+	case BBoxGeometry:
+		return "bbox"
+	case PointGeometry:
+		return "point"
+	default:
+		return "full"
+	}
+}
+
+// FeatureCollection returns the results as a GeoJSON FeatureCollection, in the same order. Each feature is the original
+// GeoJSON body for the record, read from the `geojson` table of the database it was retrieved from, with its geometry replaced
+// according to 'opts'. Labels derived for the results are added to the properties of each feature as `wof:label` and facet
+// counts are added to the FeatureCollection as a `facets` member.
+func (r *SearchResults) FeatureCollection(ctx context.Context, opts *FeatureOptions) (*geojson.FeatureCollection, error) {
+
+	if opts == nil {
+		opts = DefaultFeatureOptions()
+	}
+
+	ids := make(map[*SQLiteFullTextDatabase][]interface{})
+	order := make([]*SQLiteFullTextDatabase, 0)
+
+	for _, rr := range r.ranked {
+
+		_, exists := ids[rr.source]
+
+		if !exists {
+			order = append(order, rr.source)
+		}
+
+		ids[rr.source] = append(ids[rr.source], rr.SPR.Id())
+	}
+
+	bodies := make(map[*SQLiteFullTextDatabase]map[string][]byte)
+
+	for _, ftdb := range order {
+
+		db_bodies, err := ftdb.retrieveFeatures(ctx, ids[ftdb])
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve features for %s, %w", ftdb.db.DSN(), err)
+		}
+
+		bodies[ftdb] = db_bodies
+	}
+
+	fc := geojson.NewFeatureCollection()
+
+	if len(r.Facets) > 0 {
+		fc.ExtraMembers = geojson.Properties{
+			"facets": r.Facets,
+		}
+	}
+
+	for idx, rr := range r.ranked {
+
+		id := rr.SPR.Id()
+		body, ok := bodies[rr.source][id]
+
+		if !ok {
+			return nil, fmt.Errorf("Failed to retrieve feature for %s, record is not present in the geojson table", id)
+		}
+
+		f, err := geojson.UnmarshalFeature(body)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse feature for %s, %w", id, err)
+		}
+
+		switch opts.Geometry {
+		case BBoxGeometry:
+
+			if f.Geometry != nil {
+				bound := f.Geometry.Bound()
+				f.Geometry = bound.ToPolygon()
+				f.BBox = geojson.NewBBox(bound)
+			}
+
+		case PointGeometry:
+			f.Geometry = orb.Point{rr.SPR.Longitude(), rr.SPR.Latitude()}
+			f.BBox = nil
+		}
+
+		if f.Properties == nil {
+			f.Properties = geojson.Properties{}
+		}
+
+		result, ok := r.Places[idx].(*SearchResult)
+
+		if ok && result.Label != "" {
+			f.Properties["wof:label"] = result.Label
+		}
+
+		fc.Append(f)
+	}
+
+	return fc, nil
+}
+
+// retrieveFeatures returns the (default) GeoJSON bodies stored in the geojson table for 'ids', keyed by ID.
+func (ftdb *SQLiteFullTextDatabase) retrieveFeatures(ctx context.Context, ids []interface{}) (map[string][]byte, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ftdb.requireTables(ctx, conn, "GeoJSON output", ftdb.geojson_table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := make(map[string][]byte)
+
+	for start := 0; start < len(ids); start += maxQueryParameters {
+
+		end := start + maxQueryParameters
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		err := ftdb.retrieveFeaturesBatch(ctx, conn, ids[start:end], bodies)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bodies, nil
+}
+
+// retrieveFeaturesBatch adds the (default) GeoJSON bodies stored in the geojson table for 'ids' to 'bodies'. Results are always
+// default records so they are joined with the geojson table on their ID and an empty alt label.
+func (ftdb *SQLiteFullTextDatabase) retrieveFeaturesBatch(ctx context.Context, conn *sql.DB, ids []interface{}, bodies map[string][]byte) error {
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	q := fmt.Sprintf("SELECT id, body FROM %s WHERE alt_label = '' AND id IN (%s)", ftdb.geojson_table.Name(), placeholders)
+
+	rows, err := conn.QueryContext(ctx, q, ids...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var id int64
+		var body string
+
+		err := rows.Scan(&id, &body)
+
+		if err != nil {
+			return err
+		}
+
+		bodies[strconv.FormatInt(id, 10)] = []byte(body)
+	}
+
+	return rows.Err()
+}
@@ -3,30 +3,31 @@ module github.com/whosonfirst/go-whosonfirst-search-sqlite
 go 1.18
 
 require (
+	github.com/aaronland/go-pagination v0.2.0
 	github.com/aaronland/go-sqlite v0.2.0
+	github.com/jtacoma/uritemplates v1.0.0
+	github.com/mattn/go-sqlite3 v1.14.13
+	github.com/paulmach/orb v0.7.1
+	github.com/sfomuseum/go-edtf v1.1.1
+	github.com/tidwall/gjson v1.14.2
+	github.com/whosonfirst/go-sanitize v0.1.0
+	github.com/whosonfirst/go-whosonfirst-feature v0.0.24
+	github.com/whosonfirst/go-whosonfirst-flags v0.4.4
+	github.com/whosonfirst/go-whosonfirst-names v0.1.0
+	github.com/whosonfirst/go-whosonfirst-placetypes v0.3.0
 	github.com/whosonfirst/go-whosonfirst-search v0.1.0
 	github.com/whosonfirst/go-whosonfirst-spr/v2 v2.2.1
 	github.com/whosonfirst/go-whosonfirst-sqlite-features v0.10.0
 	github.com/whosonfirst/go-whosonfirst-sqlite-spr v0.3.2
+	golang.org/x/text v0.14.0
 )
 
 require (
-	github.com/aaronland/go-pagination v0.2.0 // indirect
 	github.com/aaronland/go-pagination-sql v0.2.0 // indirect
 	github.com/aaronland/go-roster v1.0.0 // indirect
-	github.com/jtacoma/uritemplates v1.0.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.13 // indirect
-	github.com/paulmach/orb v0.7.1 // indirect
-	github.com/sfomuseum/go-edtf v1.1.1 // indirect
-	github.com/tidwall/gjson v1.14.2 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/whosonfirst/go-rfc-5646 v0.1.0 // indirect
-	github.com/whosonfirst/go-sanitize v0.1.0 // indirect
-	github.com/whosonfirst/go-whosonfirst-feature v0.0.24 // indirect
-	github.com/whosonfirst/go-whosonfirst-flags v0.4.4 // indirect
-	github.com/whosonfirst/go-whosonfirst-names v0.1.0 // indirect
-	github.com/whosonfirst/go-whosonfirst-placetypes v0.3.0 // indirect
 	github.com/whosonfirst/go-whosonfirst-sources v0.1.0 // indirect
 	github.com/whosonfirst/go-whosonfirst-uri v1.2.0 // indirect
 )
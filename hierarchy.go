@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"strconv"
+	"strings"
+)
+
+// ParentFilter restricts results to the immediate descendants of one or more records using the `parent_id` column of the spr table.
+type ParentFilter struct {
+	nullFilter
+	ids []int64
+}
+
+// AncestorFilter restricts results to the descendants, at any depth, of one or more records using the `belongsto` column of the spr table.
+type AncestorFilter struct {
+	nullFilter
+	ids []int64
+}
+
+// NewParentFilter returns a new `ParentFilter` for records whose parent is any of 'ids'. Multiple IDs may also be separated by commas.
+func NewParentFilter(ids ...string) (*ParentFilter, error) {
+
+	parsed, err := parseHierarchyIds(ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &ParentFilter{
+		ids: parsed,
+	}
+
+	return f, nil
+}
+
+// NewAncestorFilter returns a new `AncestorFilter` for records that belong to any of 'ids'. Multiple IDs may also be separated by commas.
+func NewAncestorFilter(ids ...string) (*AncestorFilter, error) {
+
+	parsed, err := parseHierarchyIds(ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &AncestorFilter{
+		ids: parsed,
+	}
+
+	return f, nil
+}
+
+func (f *ParentFilter) WhereSQL(alias string) (string, []interface{}) {
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(f.ids)), ",")
+	args := make([]interface{}, len(f.ids))
+
+	for i, id := range f.ids {
+		args[i] = id
+	}
+
+	return fmt.Sprintf("%s.parent_id IN (%s)", alias, placeholders), args
+}
+
+func (f *ParentFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+
+	parent_id, err := strconv.ParseInt(s.ParentId(), 10, 64)
+
+	if err != nil {
+		return false
+	}
+
+	return int64InList(parent_id, f.ids)
+}
+
+func (f *ParentFilter) String() string {
+	return fmt.Sprintf("parent_id=%s", joinIds(f.ids))
+}
+
+func (f *AncestorFilter) WhereSQL(alias string) (string, []interface{}) {
+
+	// belongsto is stored as a comma-separated list of IDs so it is padded with commas
+	// in order that each ID can be matched as a whole
+
+	clauses := make([]string, len(f.ids))
+	args := make([]interface{}, len(f.ids))
+
+	for i, id := range f.ids {
+		clauses[i] = fmt.Sprintf("',' || %s.belongsto || ',' LIKE ?", alias)
+		args[i] = fmt.Sprintf("%%,%d,%%", id)
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(clauses, " OR ")), args
+}
+
+func (f *AncestorFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+
+	for _, id := range s.BelongsTo() {
+
+		if int64InList(id, f.ids) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *AncestorFilter) String() string {
+	return fmt.Sprintf("ancestor_id=%s", joinIds(f.ids))
+}
+
+func parseHierarchyIds(values []string) ([]int64, error) {
+
+	ids := make([]int64, 0)
+
+	for _, raw := range values {
+
+		for _, v := range strings.Split(raw, ",") {
+
+			v = strings.TrimSpace(v)
+
+			if v == "" {
+				continue
+			}
+
+			id, err := strconv.ParseInt(v, 10, 64)
+
+			if err != nil || id < 0 {
+				return nil, fmt.Errorf("Invalid ID '%s'", v)
+			}
+
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, errors.New("No IDs specified")
+	}
+
+	return ids, nil
+}
+
+func int64InList(i int64, list []int64) bool {
+
+	for _, candidate := range list {
+
+		if i == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinIds(ids []int64) string {
+
+	str_ids := make([]string, len(ids))
+
+	for i, id := range ids {
+		str_ids[i] = strconv.FormatInt(id, 10)
+	}
+
+	return strings.Join(str_ids, ",")
+}
@@ -0,0 +1,325 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The columns of the spr table, in the order expected by `spr.RetrieveSPRWithRows`.
+const sprColumns string = `s.id, s.parent_id, s.name, s.placetype,
+	s.inception, s.cessation,
+	s.country, s.repo,
+	s.latitude, s.longitude,
+	s.min_latitude, s.min_longitude,
+	s.max_latitude, s.max_longitude,
+	s.is_current, s.is_deprecated, s.is_ceased, s.is_superseded, s.is_superseding,
+	s.supersedes, s.superseded_by, s.belongsto,
+	s.is_alt, s.alt_label,
+	s.lastmodified`
+
+// GetByIdsResponse is the JSON encoding of the results of `GetByIds` returned by `GetByIdsHandler`.
+type GetByIdsResponse struct {
+	// The records found, in the order their IDs were requested.
+	Places []wof_spr.StandardPlacesResult `json:"places"`
+	// The IDs for which there are no records in the database, in the order they were requested.
+	Missing []int64 `json:"missing"`
+}
+
+// GetByIds returns the (default) records for 'ids' that match 'filters', in the order that their IDs are listed, along with the
+// IDs for which there are no records in the spr table. IDs listed more than once are only returned once. Records are read from the
+// spr table in batches, with filters applied in SQL where possible (see `SQLFilter`), rather than one query per ID. Records that
+// exist but don't match 'filters' are neither returned nor reported as missing.
+func (ftdb *SQLiteFullTextDatabase) GetByIds(ctx context.Context, ids []int64, filters ...filter.Filter) (wof_spr.StandardPlacesResults, []int64, error) {
+
+	ev := &QueryEvent{
+		Database: ftdb.db.DSN(),
+	}
+
+	t1 := time.Now()
+
+	ranked, missing, err := ftdb.getByIdsWithEvent(ctx, ids, ev, filters...)
+
+	ev.Latency = time.Since(t1)
+	ev.Results = len(ranked)
+	ev.Error = err
+
+	for _, o := range ftdb.observers {
+		o.ObserveQuery(ctx, ev)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := resultsFromRankedResults(ctx, ranked, ranked, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, missing, nil
+}
+
+// getByIdsWithEvent does the work of GetByIds recording the details of the query in 'ev'.
+func (ftdb *SQLiteFullTextDatabase) getByIdsWithEvent(ctx context.Context, ids []int64, ev *QueryEvent, filters ...filter.Filter) ([]*rankedResult, []int64, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unique := make([]interface{}, 0)
+	seen := make(map[int64]bool)
+
+	for _, id := range ids {
+
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		unique = append(unique, strconv.FormatInt(id, 10))
+	}
+
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	for _, f := range sql_filters {
+
+		clause, clause_args := f.WhereSQL("s")
+
+		if clause == "" {
+			continue
+		}
+
+		where = append(where, clause)
+		args = append(args, clause_args...)
+
+		ev.SQLFilters = append(ev.SQLFilters, describeFilter(f))
+	}
+
+	for _, f := range go_filters {
+		ev.GoFilters = append(ev.GoFilters, describeFilter(f))
+	}
+
+	// Each batch of IDs shares the query parameters with the arguments of any SQL filters
+
+	batch_size := maxQueryParameters - len(args)
+
+	if batch_size < 1 {
+		return nil, nil, fmt.Errorf("Filters have too many parameters (%d)", len(args))
+	}
+
+	sprs := make(map[string]wof_spr.StandardPlacesResult)
+	matches := make(map[string]bool)
+
+	t_fetch := time.Now()
+
+	for start := 0; start < len(unique); start += batch_size {
+
+		end := start + batch_size
+
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		batch := unique[start:end]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+
+		q := fmt.Sprintf("SELECT %s FROM %s s WHERE s.alt_label = '' AND s.id IN (%s)", sprColumns, ftdb.spr_table.Name(), placeholders)
+
+		ev.SQL = q
+		ev.args = batch
+
+		err := ftdb.getByIdsBatch(ctx, conn, q, batch, sprs)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(where) == 0 {
+			continue
+		}
+
+		// Records are retrieved without filters so that those which don't match can be told apart from those which are missing
+
+		filter_q := fmt.Sprintf("SELECT s.id FROM %s s WHERE s.alt_label = '' AND s.id IN (%s) AND %s", ftdb.spr_table.Name(), placeholders, strings.Join(where, " AND "))
+		filter_args := append(append([]interface{}{}, batch...), args...)
+
+		filter_ids, err := ftdb.browseIds(ctx, conn, filter_q, filter_args...)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to apply filters, %w", err)
+		}
+
+		for _, id := range filter_ids {
+			matches[id] = true
+		}
+	}
+
+	ev.RowsMatched = len(sprs)
+	ev.SPRFetchTime = time.Since(t_fetch)
+	ev.addStage("fetch", t_fetch)
+
+	t_filter := time.Now()
+
+	ranked := make([]*rankedResult, 0)
+	missing := make([]int64, 0)
+
+	for _, v := range unique {
+
+		str_id := v.(string)
+		spr_r, ok := sprs[str_id]
+
+		if !ok {
+
+			id, err := strconv.ParseInt(str_id, 10, 64)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			missing = append(missing, id)
+			continue
+		}
+
+		if len(where) > 0 && !matches[str_id] {
+			continue
+		}
+
+		if !matchesFilters(spr_r, go_filters...) {
+			continue
+		}
+
+		ranked = append(ranked, &rankedResult{
+			SPR:    spr_r,
+			Score:  1.0,
+			Index:  len(ranked),
+			source: ftdb,
+		})
+	}
+
+	ev.addStage("filter", t_filter)
+
+	return ranked, missing, nil
+}
+
+// getByIdsBatch adds the SPRs for the rows returned by 'q', which is expected to select `sprColumns`, to 'sprs' keyed by ID.
+func (ftdb *SQLiteFullTextDatabase) getByIdsBatch(ctx context.Context, conn *sql.DB, q string, args []interface{}, sprs map[string]wof_spr.StandardPlacesResult) error {
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		spr_r, err := spr.RetrieveSPRWithRows(ctx, rows)
+
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve SPR, %w", err)
+		}
+
+		sprs[spr_r.Id()] = spr_r
+	}
+
+	return rows.Err()
+}
+
+// ParseIds returns the Who's On First IDs in 'str_ids', each of which may contain a comma-separated list of IDs.
+func ParseIds(str_ids ...string) ([]int64, error) {
+
+	ids := make([]int64, 0)
+
+	for _, str := range str_ids {
+
+		for _, str_id := range strings.Split(str, ",") {
+
+			str_id = strings.TrimSpace(str_id)
+
+			if str_id == "" {
+				continue
+			}
+
+			id, err := strconv.ParseInt(str_id, 10, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ID '%s'", str_id)
+			}
+
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// GetByIdsHandler returns an `http.Handler` that writes the records for the IDs listed in the `id` query parameter, which may be
+// repeated or contain a comma-separated list of IDs, as a JSON encoded `GetByIdsResponse`. Results are filtered using the filters
+// defined by the other query parameters (see `NewFiltersFromQuery`), for example ?id=85633041,85633793&is_current=1
+func GetByIdsHandler(ftdb *SQLiteFullTextDatabase) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		query := req.URL.Query()
+
+		ids, err := ParseIds(query["id"]...)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if len(ids) == 0 {
+			http.Error(rsp, "Missing id parameter", http.StatusBadRequest)
+			return
+		}
+
+		query.Del("id")
+
+		filters, err := NewFiltersFromQuery(query)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results, missing, err := ftdb.GetByIds(req.Context(), ids, filters...)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ids_rsp := &GetByIdsResponse{
+			Places:  results.Results(),
+			Missing: missing,
+		}
+
+		rsp.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(rsp).Encode(ids_rsp)
+
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getByIds returns the IDs of the records returned by `GetByIds` for 'ids' and 'filters', in the order they are returned, along
+// with the IDs reported as missing.
+func getByIds(t *testing.T, ftdb *SQLiteFullTextDatabase, ids []int64, filters ...filter.Filter) ([]string, []int64) {
+
+	t.Helper()
+
+	r, missing, err := ftdb.GetByIds(context.Background(), ids, filters...)
+
+	if err != nil {
+		t.Fatalf("Failed to get records for %d IDs, %v", len(ids), err)
+	}
+
+	found := make([]string, 0)
+
+	for _, s := range r.Results() {
+		found = append(found, s.Id())
+	}
+
+	return found, missing
+}
+
+func equalInt64s(a []int64, b []int64) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, v := range a {
+
+		if b[i] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestGetByIds(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(1, "Montreal", 45.51, -73.59, nil),
+		testFeature(2, "Paris", 48.86, 2.35, map[string]interface{}{
+			"wof:country": "FR",
+		}),
+		testFeature(3, "Lyon", 45.76, 4.84, map[string]interface{}{
+			"wof:country": "FR",
+		}),
+	}
+
+	ftdb := newTestDatabase(t, "", features...)
+
+	// Records are returned in the order their IDs are listed and IDs listed more than once are returned once
+
+	found, missing := getByIds(t, ftdb, []int64{3, 1, 3, 99, 2, 98, 1})
+
+	expected := []string{"3", "1", "2"}
+
+	if !equalIds(found, expected) {
+		t.Errorf("Expected records %v but got %v", expected, found)
+	}
+
+	expected_missing := []int64{99, 98}
+
+	if !equalInt64s(missing, expected_missing) {
+		t.Errorf("Expected missing IDs %v but got %v", expected_missing, missing)
+	}
+
+	// Records that don't match the filters are neither returned nor missing
+
+	f, err := NewCountryFilter("FR")
+
+	if err != nil {
+		t.Fatalf("Failed to create country filter, %v", err)
+	}
+
+	found, missing = getByIds(t, ftdb, []int64{1, 2, 99, 3}, f)
+
+	expected = []string{"2", "3"}
+
+	if !equalIds(found, expected) {
+		t.Errorf("Expected filtered records %v but got %v", expected, found)
+	}
+
+	expected_missing = []int64{99}
+
+	if !equalInt64s(missing, expected_missing) {
+		t.Errorf("Expected missing IDs %v but got %v", expected_missing, missing)
+	}
+}
+
+func TestGetByIdsBatches(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(5, "Montreal", 45.51, -73.59, nil),
+		testFeature(500, "Paris", 48.86, 2.35, map[string]interface{}{
+			"wof:country": "FR",
+		}),
+		testFeature(1050, "Lyon", 45.76, 4.84, map[string]interface{}{
+			"wof:country": "FR",
+		}),
+		testFeature(1200, "Geneva", 46.20, 6.14, map[string]interface{}{
+			"wof:country": "CH",
+		}),
+	}
+
+	ftdb := newTestDatabase(t, "", features...)
+
+	// More IDs than the maximum number of query parameters, in descending order
+
+	ids := make([]int64, 0)
+
+	for id := int64(1200); id > 0; id-- {
+		ids = append(ids, id)
+	}
+
+	found, missing := getByIds(t, ftdb, ids)
+
+	expected := []string{"1200", "1050", "500", "5"}
+
+	if !equalIds(found, expected) {
+		t.Errorf("Expected records %v but got %v", expected, found)
+	}
+
+	if len(missing) != len(ids)-len(features) {
+		t.Errorf("Expected %d missing IDs but got %d", len(ids)-len(features), len(missing))
+	}
+
+	// Filters with many parameters reduce the number of IDs in each batch
+
+	codes := []string{"FR", "XX"}
+
+	for a := 'A'; a <= 'Z' && len(codes) < 600; a++ {
+
+		for b := 'A'; b <= 'Z' && len(codes) < 600; b++ {
+
+			code := fmt.Sprintf("%c%c", a, b)
+
+			if code == "CH" || code == "FR" || code == "XX" {
+				continue
+			}
+
+			codes = append(codes, code)
+		}
+	}
+
+	f, err := NewCountryFilter(codes...)
+
+	if err != nil {
+		t.Fatalf("Failed to create country filter, %v", err)
+	}
+
+	found, missing = getByIds(t, ftdb, ids, f)
+
+	expected = []string{"1050", "500", "5"}
+
+	if !equalIds(found, expected) {
+		t.Errorf("Expected filtered records %v but got %v", expected, found)
+	}
+
+	if len(missing) != len(ids)-len(features) {
+		t.Errorf("Expected %d missing IDs but got %d", len(ids)-len(features), len(missing))
+	}
+}
+
+func TestGetByIdsHandler(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "", testFeature(1, "Montreal", 45.51, -73.59, nil))
+
+	handler := GetByIdsHandler(ftdb)
+
+	tests := map[string]int{
+		"/?id=1":           http.StatusOK,
+		"/?id=1,99":        http.StatusOK,
+		"/?id=abc":         http.StatusBadRequest,
+		"/?id=1,abc":       http.StatusBadRequest,
+		"/":                http.StatusBadRequest,
+		"/?id=1&country=X": http.StatusBadRequest,
+	}
+
+	for uri, expected := range tests {
+
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, httptest.NewRequest("GET", uri, nil))
+
+		if rsp.Code != expected {
+			t.Errorf("Expected status %d for %s but got %d", expected, uri, rsp.Code)
+		}
+	}
+
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, httptest.NewRequest("GET", "/?id=99,1", nil))
+
+	var ids_rsp struct {
+		Places  []map[string]interface{} `json:"places"`
+		Missing []int64                  `json:"missing"`
+	}
+
+	err := json.Unmarshal(rsp.Body.Bytes(), &ids_rsp)
+
+	if err != nil {
+		t.Fatalf("Failed to decode response, %v", err)
+	}
+
+	if len(ids_rsp.Places) != 1 || !equalInt64s(ids_rsp.Missing, []int64{99}) {
+		t.Errorf("Expected one place and missing ID 99 but got %s", rsp.Body.String())
+	}
+}
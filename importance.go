@@ -0,0 +1,273 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-placetypes"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The maximum sum of the weights in an `ImportanceWeights` instance. Importance can lower a result's relevance by at most this
+// fraction so that relevance always dominates. Results matched phonetically are still ranked below the others even when the
+// focus reduction is also applied (see `maxFocusWeight`).
+const maxImportanceWeight float64 = 0.5
+
+// The population of a place whose population signal is 1.0. Populations are compared on a logarithmic scale.
+const maxImportancePopulation float64 = 1e8
+
+// The largest number of ancestors of any placetype in the placetype hierarchy, for example installation, so that the most
+// specific placetypes have a placetype signal of 0.0. It is derived from the placetype specification when the package is initialized.
+var maxPlacetypeDepth float64
+
+func init() {
+
+	pts, err := placetypes.Placetypes()
+
+	if err != nil {
+		log.Fatalf("Failed to load placetypes, %v", err)
+	}
+
+	for _, pt := range pts {
+		maxPlacetypeDepth = math.Max(maxPlacetypeDepth, float64(placetypeDepth(pt.Name)))
+	}
+}
+
+// The property paths read from the properties table to derive the importance of a record.
+const (
+	populationProperty string = "wof:population"
+	megacityProperty   string = "wof:megacity"
+)
+
+// ImportanceWeights defines how much each signal of a record's importance contributes to its relevance. Each signal is in the
+// range 0.0 - 1.0 and a record's relevance is multiplied by (1.0 - {SUM OF WEIGHTS}) + {SUM OF WEIGHTED SIGNALS}, so a record with
+// every signal at its maximum keeps its relevance and less important records are ranked below it when they are otherwise equally
+// relevant. The sum of the weights must not be greater than 0.5.
+type ImportanceWeights struct {
+	// The weight of a record's `wof:population` property, on a logarithmic scale up to 100,000,000. Records without a population
+	// have a signal of 0.0. Populations are read from the properties table.
+	Population float64
+	// The weight of a record's `wof:megacity` property, read from the properties table. Megacities have a signal of 1.0.
+	Megacity float64
+	// The weight of a record's placetype, relative to its depth in the placetype hierarchy, so that countries have a higher
+	// signal than localities which have a higher signal than neighbourhoods.
+	Placetype float64
+	// The weight of whether a record is current. Current records have a signal of 1.0, records which aren't 0.0 and records whose
+	// status is unknown 0.5.
+	Current float64
+}
+
+// DefaultImportanceWeights returns a new `ImportanceWeights` instance with weights that favour populous and current places.
+func DefaultImportanceWeights() *ImportanceWeights {
+
+	w := &ImportanceWeights{
+		Population: 0.15,
+		Megacity:   0.05,
+		Placetype:  0.05,
+		Current:    0.05,
+	}
+
+	return w
+}
+
+// ParseImportanceWeights returns a new `ImportanceWeights` instance for 'str' which is either "default" (see `DefaultImportanceWeights`)
+// or a comma-separated list of {SIGNAL}={WEIGHT} pairs, for example "population=0.2,current=0.1". Valid signals are population,
+// megacity, placetype and current. Signals that are not listed have a weight of 0.0.
+func ParseImportanceWeights(str string) (*ImportanceWeights, error) {
+
+	if str == "default" {
+		return DefaultImportanceWeights(), nil
+	}
+
+	w := new(ImportanceWeights)
+
+	for _, pair := range strings.Split(str, ",") {
+
+		pair = strings.TrimSpace(pair)
+
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid importance weight '%s'", pair)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid importance weight '%s', %w", pair, err)
+		}
+
+		switch strings.TrimSpace(parts[0]) {
+		case "population":
+			w.Population = weight
+		case "megacity":
+			w.Megacity = weight
+		case "placetype":
+			w.Placetype = weight
+		case "current":
+			w.Current = weight
+		default:
+			return nil, fmt.Errorf("Invalid importance signal '%s'", parts[0])
+		}
+	}
+
+	err := w.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Validate ensures that none of the weights are negative and that their sum is not greater than 0.5.
+func (w *ImportanceWeights) Validate() error {
+
+	for _, weight := range []float64{w.Population, w.Megacity, w.Placetype, w.Current} {
+
+		if weight < 0.0 {
+			return fmt.Errorf("Invalid importance weight %f, weights must not be negative", weight)
+		}
+	}
+
+	if w.total() > maxImportanceWeight {
+		return fmt.Errorf("Invalid importance weights, their sum must not be greater than %.1f", maxImportanceWeight)
+	}
+
+	return nil
+}
+
+func (w *ImportanceWeights) String() string {
+	return fmt.Sprintf("population=%g,megacity=%g,placetype=%g,current=%g", w.Population, w.Megacity, w.Placetype, w.Current)
+}
+
+// total returns the sum of the weights.
+func (w *ImportanceWeights) total() float64 {
+	return w.Population + w.Megacity + w.Placetype + w.Current
+}
+
+// propertyPaths returns the property paths that need to be read from the properties table to derive importance using 'w'.
+func (w *ImportanceWeights) propertyPaths() []string {
+
+	paths := make([]string, 0)
+
+	if w.Population > 0.0 {
+		paths = append(paths, populationProperty)
+	}
+
+	if w.Megacity > 0.0 {
+		paths = append(paths, megacityProperty)
+	}
+
+	return paths
+}
+
+// factor returns the amount the relevance of 'r' is multiplied by, in the range (1.0 - {SUM OF WEIGHTS}) - 1.0. The properties of 'r'
+// are expected to have already been read (see `propertyPaths`).
+func (w *ImportanceWeights) factor(r *rankedResult) float64 {
+
+	f := 1.0 - w.total()
+
+	if w.Population > 0.0 {
+		f += w.Population * populationSignal(r.properties[populationProperty])
+	}
+
+	if w.Megacity > 0.0 && propertyNumber(r.properties[megacityProperty]) == 1.0 {
+		f += w.Megacity
+	}
+
+	if w.Placetype > 0.0 {
+		f += w.Placetype * placetypeSignal(r.SPR.Placetype())
+	}
+
+	if w.Current > 0.0 {
+
+		switch r.SPR.IsCurrent().Flag() {
+		case 1:
+			f += w.Current
+		case 0:
+			// pass
+		default:
+			f += w.Current * 0.5
+		}
+	}
+
+	return f
+}
+
+// populationSignal returns the importance signal for the population 'v', a property value, on a logarithmic scale.
+func populationSignal(v interface{}) float64 {
+
+	population := propertyNumber(v)
+
+	if population <= 0.0 {
+		return 0.0
+	}
+
+	return math.Min(1.0, math.Log10(population+1.0)/math.Log10(maxImportancePopulation))
+}
+
+// placetypeSignal returns the importance signal for the placetype 'name' relative to its depth in the placetype hierarchy.
+// Invalid placetypes have a signal of 0.0.
+func placetypeSignal(name string) float64 {
+
+	depth := placetypeDepth(name)
+
+	if depth < 0 || maxPlacetypeDepth == 0.0 {
+		return 0.0
+	}
+
+	// Placetypes appended to the specification after the package is initialized may have more ancestors than maxPlacetypeDepth
+
+	return math.Max(0.0, 1.0-float64(depth)/maxPlacetypeDepth)
+}
+
+// propertyNumber returns the property value 'v' as a number or 0.0 if it is not numeric.
+func propertyNumber(v interface{}) float64 {
+
+	switch v.(type) {
+	case float64:
+		return v.(float64)
+	case string:
+
+		f, err := strconv.ParseFloat(v.(string), 64)
+
+		if err != nil {
+			return 0.0
+		}
+
+		return f
+
+	default:
+		return 0.0
+	}
+}
+
+// applyImportance multiplies the relevance of each of 'ranked' by its importance according to 'w', reading any properties
+// that are needed from the properties table of the database each result was retrieved from.
+func applyImportance(ctx context.Context, ranked []*rankedResult, w *ImportanceWeights) error {
+
+	err := w.Validate()
+
+	if err != nil {
+		return err
+	}
+
+	err = loadRankedProperties(ctx, ranked, w.propertyPaths())
+
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranked {
+		r.Score = r.Score * w.factor(r)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+// parisFeatures returns three localities named Paris, the least populous first, so that they are returned in ascending order of
+// population when ranked by relevance alone.
+func parisFeatures() [][]byte {
+
+	return [][]byte{
+		testFeature(1360000001, "Paris", 46.02, -71.01, map[string]interface{}{
+			"wof:country":    "CA",
+			"wof:population": 12,
+		}),
+		testFeature(101725629, "Paris", 33.65, -95.55, map[string]interface{}{
+			"wof:country":    "US",
+			"wof:population": 25000,
+		}),
+		testFeature(101751119, "Paris", 48.86, 2.35, map[string]interface{}{
+			"wof:country":    "FR",
+			"wof:population": 2161000,
+			"wof:megacity":   1,
+		}),
+	}
+}
+
+func TestImportancePopulation(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "", parisFeatures()...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	ids := queryIds(t, ftdb, "paris", opts)
+	expected := []string{"1360000001", "101725629", "101751119"}
+
+	if !equalIds(ids, expected) {
+		t.Fatalf("Expected results to be ranked %v without importance but got %v", expected, ids)
+	}
+
+	opts.Importance = DefaultImportanceWeights()
+
+	ids = queryIds(t, ftdb, "paris", opts)
+	expected = []string{"101751119", "101725629", "1360000001"}
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected results to be ranked %v with the default importance weights but got %v", expected, ids)
+	}
+}
+
+func TestImportanceSignals(t *testing.T) {
+
+	tests := []struct {
+		Signal   string
+		Weights  *ImportanceWeights
+		Features [][]byte
+		Expected []string
+	}{
+		{
+			Signal:  "megacity",
+			Weights: &ImportanceWeights{Megacity: 0.1},
+			Features: [][]byte{
+				testFeature(1, "Springfield", 0.0, 0.0, nil),
+				testFeature(2, "Springfield", 0.0, 0.0, map[string]interface{}{
+					"wof:megacity": 1,
+				}),
+			},
+			Expected: []string{"2", "1"},
+		},
+		{
+			Signal:  "placetype",
+			Weights: &ImportanceWeights{Placetype: 0.1},
+			Features: [][]byte{
+				testFeature(1, "Springfield", 0.0, 0.0, map[string]interface{}{
+					"wof:placetype": "neighbourhood",
+				}),
+				testFeature(2, "Springfield", 0.0, 0.0, nil),
+				testFeature(3, "Springfield", 0.0, 0.0, map[string]interface{}{
+					"wof:placetype": "region",
+				}),
+			},
+			Expected: []string{"3", "2", "1"},
+		},
+		{
+			Signal:  "current",
+			Weights: &ImportanceWeights{Current: 0.1},
+			Features: [][]byte{
+				testFeature(1, "Springfield", 0.0, 0.0, map[string]interface{}{
+					"mz:is_current": 0,
+				}),
+				testFeature(2, "Springfield", 0.0, 0.0, map[string]interface{}{
+					"mz:is_current": -1,
+				}),
+				testFeature(3, "Springfield", 0.0, 0.0, nil),
+			},
+			Expected: []string{"3", "2", "1"},
+		},
+	}
+
+	for _, test := range tests {
+
+		ftdb := newTestDatabase(t, "", test.Features...)
+
+		opts, err := DefaultQueryOptions()
+
+		if err != nil {
+			t.Fatalf("Failed to create query options, %v", err)
+		}
+
+		opts.Importance = test.Weights
+
+		ids := queryIds(t, ftdb, "springfield", opts)
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected the %s signal to rank results %v but got %v", test.Signal, test.Expected, ids)
+		}
+	}
+}
+
+func TestImportanceZeroWeights(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "", parisFeatures()...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	expected := queryIds(t, ftdb, "paris", opts)
+
+	opts.Importance = &ImportanceWeights{}
+
+	ids := queryIds(t, ftdb, "paris", opts)
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected zero importance weights to leave results ranked %v but got %v", expected, ids)
+	}
+}
+
+func TestParseImportanceWeights(t *testing.T) {
+
+	valid := map[string]*ImportanceWeights{
+		"default":                    DefaultImportanceWeights(),
+		"population=0.2,current=0.1": &ImportanceWeights{Population: 0.2, Current: 0.1},
+		"megacity=0.5":               &ImportanceWeights{Megacity: 0.5},
+		"":                           &ImportanceWeights{},
+	}
+
+	for str, expected := range valid {
+
+		w, err := ParseImportanceWeights(str)
+
+		if err != nil {
+			t.Errorf("Failed to parse importance weights '%s', %v", str, err)
+			continue
+		}
+
+		if *w != *expected {
+			t.Errorf("Expected '%s' to be parsed as %s but got %s", str, expected, w)
+		}
+	}
+
+	invalid := []string{
+		// Negative weights
+		"population=-0.1",
+		// Weights whose sum is greater than 0.5
+		"population=0.3,megacity=0.3",
+		"population=0.2,megacity=0.1,placetype=0.1,current=0.2",
+		// Unknown signals
+		"fame=0.1",
+		// Malformed pairs
+		"population",
+		"population=high",
+	}
+
+	for _, str := range invalid {
+
+		_, err := ParseImportanceWeights(str)
+
+		if err == nil {
+			t.Errorf("Expected '%s' to be rejected", str)
+		}
+	}
+}
+
+func TestPlacetypeSignal(t *testing.T) {
+
+	// Installations have the most ancestors of any placetype so they, and not venues, have the lowest signal
+
+	if placetypeDepth("installation") != int(maxPlacetypeDepth) {
+		t.Errorf("Expected the maximum placetype depth to be the depth of installation (%d) but got %f", placetypeDepth("installation"), maxPlacetypeDepth)
+	}
+
+	ordered := []string{"country", "region", "locality", "neighbourhood", "venue", "installation"}
+
+	for idx := 1; idx < len(ordered); idx++ {
+
+		if placetypeSignal(ordered[idx]) >= placetypeSignal(ordered[idx-1]) {
+			t.Errorf("Expected the %s placetype signal to be less than the %s placetype signal", ordered[idx], ordered[idx-1])
+		}
+	}
+
+	if placetypeSignal("installation") != 0.0 {
+		t.Errorf("Expected the installation placetype signal to be 0.0 but got %f", placetypeSignal("installation"))
+	}
+}
@@ -0,0 +1,203 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-placetypes"
+	"sort"
+	"strings"
+)
+
+// LabelOptions defines how human-readable labels are derived from the names of a record's ancestors.
+type LabelOptions struct {
+	// The placetypes of the ancestors whose names are included in a label, after the record's own name. Names are always
+	// ordered from most to least specific, according to go-whosonfirst-placetypes, regardless of the order of this list.
+	Placetypes []string
+	// If true use an ancestor's short name (its `wof:shortcode` property or, for countries, its country code) when one is available.
+	ShortNames bool
+	// The string used to join the names in a label.
+	Separator string
+}
+
+// DefaultLabelOptions returns a new LabelOptions instance that will produce labels like "Montreal, Quebec, Canada".
+func DefaultLabelOptions() *LabelOptions {
+
+	opts := &LabelOptions{
+		Placetypes: []string{"locality", "region", "country"},
+		ShortNames: false,
+		Separator:  ", ",
+	}
+
+	return opts
+}
+
+// ancestorName is the name of one of a record's ancestors, as stored in the spr table.
+type ancestorName struct {
+	Id        int64
+	Placetype string
+	Name      string
+	Country   string
+	// The position of Placetype in the placetype hierarchy. Larger values are more specific.
+	depth int
+}
+
+// placetypeDepth returns the number of ancestors 'name' has in the placetype hierarchy or -1 if 'name' is not a valid placetype.
+func placetypeDepth(name string) int {
+
+	pt, err := placetypes.GetPlacetypeByName(name)
+
+	if err != nil {
+		return -1
+	}
+
+	roles := []string{
+		"common",
+		"optional",
+		"common_optional",
+	}
+
+	return len(placetypes.AncestorsForRoles(pt, roles))
+}
+
+// validateLabelOptions ensures that all the placetypes in 'opts' are valid.
+func validateLabelOptions(opts *LabelOptions) error {
+
+	if len(opts.Placetypes) == 0 {
+		return errors.New("Label options must define one or more placetypes")
+	}
+
+	for _, pt := range opts.Placetypes {
+
+		if !placetypes.IsValidPlacetype(pt) {
+			return fmt.Errorf("Invalid label placetype '%s'", pt)
+		}
+	}
+
+	return nil
+}
+
+// label returns a human-readable label for the record 'id', named 'name', composed of its own name followed by the names of its
+// ancestors whose placetypes are listed in 'opts'. Ancestors are read from the ancestors table and their names from the spr table.
+// If 'has_properties' is true then short names are read from the properties table.
+func (ftdb *SQLiteFullTextDatabase) label(ctx context.Context, conn *sql.DB, id string, name string, opts *LabelOptions, has_properties bool) (string, error) {
+
+	q := fmt.Sprintf(`SELECT a.ancestor_id, a.ancestor_placetype, s.name, s.country FROM %s a
+		JOIN %s s ON s.id = CAST(a.ancestor_id AS TEXT)
+		WHERE a.id = ? AND a.ancestor_id != a.id AND s.is_alt = 0
+		ORDER BY a.ancestor_id ASC`, ftdb.ancestors_table.Name(), ftdb.spr_table.Name())
+
+	rows, err := conn.QueryContext(ctx, q, id)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+
+	wanted := make(map[string]bool)
+
+	for _, pt := range opts.Placetypes {
+		wanted[pt] = true
+	}
+
+	// A record may have more than one hierarchy in which case the first ancestor, ordered by ID, for each placetype is used.
+	seen := make(map[string]bool)
+	ancestors := make([]*ancestorName, 0)
+
+	for rows.Next() {
+
+		a := new(ancestorName)
+
+		err := rows.Scan(&a.Id, &a.Placetype, &a.Name, &a.Country)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !wanted[a.Placetype] || seen[a.Placetype] {
+			continue
+		}
+
+		a.depth = placetypeDepth(a.Placetype)
+
+		seen[a.Placetype] = true
+		ancestors = append(ancestors, a)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return "", err
+	}
+
+	sort.SliceStable(ancestors, func(i, j int) bool {
+		return ancestors[i].depth > ancestors[j].depth
+	})
+
+	parts := []string{
+		name,
+	}
+
+	for _, a := range ancestors {
+
+		a_name := a.Name
+
+		if opts.ShortNames {
+
+			short, err := ftdb.shortName(ctx, conn, a, has_properties)
+
+			if err != nil {
+				return "", fmt.Errorf("Failed to retrieve short name for %d, %w", a.Id, err)
+			}
+
+			if short != "" {
+				a_name = short
+			}
+		}
+
+		if a_name == "" || a_name == parts[len(parts)-1] {
+			continue
+		}
+
+		parts = append(parts, a_name)
+	}
+
+	return strings.Join(parts, opts.Separator), nil
+}
+
+// shortName returns the short name for 'a', or an empty string if it doesn't have one. The `wof:shortcode` property is
+// read from the properties table if 'has_properties' is true. Countries without a short code use their country code.
+func (ftdb *SQLiteFullTextDatabase) shortName(ctx context.Context, conn *sql.DB, a *ancestorName, has_properties bool) (string, error) {
+
+	if has_properties {
+
+		q := fmt.Sprintf("SELECT body FROM %s WHERE id = ? AND is_alt = 0 LIMIT 1", ftdb.properties_table.Name())
+
+		var body string
+
+		err := conn.QueryRowContext(ctx, q, a.Id).Scan(&body)
+
+		switch {
+		case err == sql.ErrNoRows:
+			// pass
+		case err != nil:
+			return "", err
+		default:
+
+			rsp := gjson.Get(body, "wof:shortcode")
+
+			if rsp.Exists() && rsp.String() != "" {
+				return rsp.String(), nil
+			}
+		}
+	}
+
+	if a.Placetype == "country" {
+		return a.Country, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,233 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github.com/whosonfirst/go-sanitize"
+	"strings"
+	"unicode"
+)
+
+// MatchMode defines how search terms are converted in to full-text MATCH expressions.
+type MatchMode uint8
+
+const (
+	// PlainTextMatch treats search terms as plain text. Each whitespace-separated token is quoted so that full-text
+	// query syntax (for example `"`, `-`, `*`, `:`, `NEAR`, `OR` or parentheses) is matched literally rather than interpreted.
+	PlainTextMatch MatchMode = iota
+	// RawMatch passes search terms to SQLite as full-text query expressions, after checking that quotes and parentheses are balanced.
+	RawMatch
+	// PhoneticMatch treats search terms as plain text and also matches records whose names sound like them (see `Metaphone`). Records that
+	// are only matched phonetically are ranked below the others. It requires a database created with the `phonetic` parameter.
+	PhoneticMatch
+)
+
+// ErrEmptyQuery is returned when a search term does not contain anything that can be matched.
+var ErrEmptyQuery = errors.New("Query does not contain any searchable terms")
+
+// InvalidQueryError is returned when a search term can not be parsed as a full-text query expression.
+type InvalidQueryError struct {
+	// The search term that failed to parse.
+	Term string
+	// A description of why the search term failed to parse.
+	Reason string
+	err    error
+}
+
+func (e *InvalidQueryError) Error() string {
+	return fmt.Sprintf("Invalid query '%s', %s", e.Term, e.Reason)
+}
+
+func (e *InvalidQueryError) Unwrap() error {
+	return e.err
+}
+
+// tokenExpander returns the alternative phrases, if any, that a (plain text) search token should also match. Alternatives
+// are lower-cased words separated by spaces.
+type tokenExpander func(token string) []string
+
+var sanitizeOpts *sanitize.Options
+
+func init() {
+	sanitizeOpts = sanitize.DefaultOptions()
+}
+
+// ParseMatchMode returns the `MatchMode` for 'str' which is expected to be "plain", "raw" or "phonetic".
+func ParseMatchMode(str string) (MatchMode, error) {
+
+	switch strings.ToLower(str) {
+	case "plain", "":
+		return PlainTextMatch, nil
+	case "raw":
+		return RawMatch, nil
+	case "phonetic":
+		return PhoneticMatch, nil
+	default:
+		return 0, fmt.Errorf("Invalid match mode '%s'", str)
+	}
+}
+
+func (m MatchMode) String() string {
+
+	switch m {
+	case RawMatch:
+		return "raw"
+	case PhoneticMatch:
+		return "phonetic"
+	default:
+		return "plain"
+	}
+}
+
+// matchExpression returns the full-text MATCH expression for 'term' according to 'mode'. 'expanders' are used to add alternatives
+// for each token when 'mode' is `PlainTextMatch` or `PhoneticMatch`. The phonetic codes matched by `PhoneticMatch` are derived separately
+// (see `phoneticExpression`).
+func matchExpression(term string, mode MatchMode, expanders ...tokenExpander) (string, error) {
+
+	clean, err := sanitize.SanitizeString(term, sanitizeOpts)
+
+	if err != nil {
+		return "", &InvalidQueryError{Term: term, Reason: err.Error(), err: err}
+	}
+
+	clean = strings.TrimSpace(clean)
+
+	if clean == "" {
+		return "", ErrEmptyQuery
+	}
+
+	switch mode {
+	case RawMatch:
+
+		err := validateRawExpression(clean)
+
+		if err != nil {
+			return "", &InvalidQueryError{Term: term, Reason: err.Error(), err: err}
+		}
+
+		return clean, nil
+
+	default:
+		return plainTextExpression(clean, expanders...)
+	}
+}
+
+// plainTextExpression quotes each of the tokens in 'term' so they are treated as literal phrases. Double quotes
+// can not be escaped in FTS4 phrases and asterisks are still treated as prefix operators inside them so both are
+// removed, as are tokens without any letters or numbers. Tokens with alternatives defined by 'expanders' are matched by
+// any of them, for example ("st" OR "saint").
+func plainTextExpression(term string, expanders ...tokenExpander) (string, error) {
+
+	phrases := make([]string, 0)
+
+	for _, token := range strings.Fields(term) {
+
+		token = strings.ReplaceAll(token, `"`, "")
+		token = strings.ReplaceAll(token, "*", "")
+
+		if !hasSearchableRunes(token) {
+			continue
+		}
+
+		alternatives := []string{
+			fmt.Sprintf(`"%s"`, token),
+		}
+
+		for _, expand := range expanders {
+
+			for _, alt := range expand(token) {
+
+				alt = fmt.Sprintf(`"%s"`, alt)
+
+				if !stringInList(alt, alternatives) {
+					alternatives = append(alternatives, alt)
+				}
+			}
+		}
+
+		if len(alternatives) == 1 {
+			phrases = append(phrases, alternatives[0])
+			continue
+		}
+
+		phrases = append(phrases, fmt.Sprintf("(%s)", strings.Join(alternatives, " OR ")))
+	}
+
+	if len(phrases) == 0 {
+		return "", ErrEmptyQuery
+	}
+
+	return strings.Join(phrases, " "), nil
+}
+
+func hasSearchableRunes(token string) bool {
+
+	for _, r := range token {
+
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateRawExpression checks that the quotes and parentheses in 'expr' are balanced.
+func validateRawExpression(expr string) error {
+
+	depth := 0
+	quoted := false
+
+	for _, r := range expr {
+
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+			continue
+		case r == '(':
+			depth += 1
+		case r == ')':
+
+			depth -= 1
+
+			if depth < 0 {
+				return errors.New("unbalanced parentheses")
+			}
+		}
+	}
+
+	if quoted {
+		return errors.New("unbalanced quotes")
+	}
+
+	if depth != 0 {
+		return errors.New("unbalanced parentheses")
+	}
+
+	return nil
+}
+
+// wrapMatchError returns an `InvalidQueryError` if 'err' was triggered by SQLite failing to parse a full-text
+// query expression. Otherwise 'err' is returned unchanged.
+func wrapMatchError(term string, err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	msg := err.Error()
+
+	for _, fragment := range []string{
+		"malformed MATCH expression",
+		"unable to use function MATCH",
+		"fts5: syntax error",
+	} {
+
+		if strings.Contains(msg, fragment) {
+			return &InvalidQueryError{Term: term, Reason: msg, err: err}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,316 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	"github.com/whosonfirst/go-whosonfirst-search/fulltext"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// SQLiteMultiFullTextDatabase implements the `fulltext.FullTextDatabase` interface by querying multiple
+// `SQLiteFullTextDatabase` instances in parallel and merging their results.
+type SQLiteMultiFullTextDatabase struct {
+	fulltext.FullTextDatabase
+	databases []*SQLiteFullTextDatabase
+}
+
+func init() {
+	ctx := context.Background()
+	fulltext.RegisterFullTextDatabase(ctx, "sqlite-multi", NewSQLiteMultiFullTextDatabase)
+}
+
+// NewSQLiteMultiFullTextDatabase returns a new `SQLiteMultiFullTextDatabase` instance configured by 'str_uri' which
+// is expected to take the form of:
+//
+//	sqlite-multi://?dsn={DSN}&dsn={DSN}
+//
+// Each 'dsn' parameter may be a path to a SQLite database or a glob pattern (for example "/usr/local/data/*-latest.db").
+// Any other query parameters are passed to each of the underlying `SQLiteFullTextDatabase` instances.
+//
+// Queries are performed against every database. If any one of them fails the query fails, and its error is returned, rather than
+// returning partial results from the others.
+func NewSQLiteMultiFullTextDatabase(ctx context.Context, str_uri string) (fulltext.FullTextDatabase, error) {
+
+	u, err := url.Parse(str_uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+
+	dsn_list, err := expandDSNs(q["dsn"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dsn_list) == 0 {
+		return nil, errors.New("Missing 'dsn' parameter")
+	}
+
+	databases := make([]*SQLiteFullTextDatabase, 0)
+
+	for _, dsn := range dsn_list {
+
+		db_q := url.Values{}
+
+		for k, v := range q {
+			db_q[k] = v
+		}
+
+		db_q.Set("dsn", dsn)
+
+		db_uri := url.URL{}
+		db_uri.Scheme = "sqlite"
+		db_uri.RawQuery = db_q.Encode()
+
+		db, err := NewSQLiteFullTextDatabase(ctx, db_uri.String())
+
+		if err != nil {
+
+			for _, d := range databases {
+				d.Close(ctx)
+			}
+
+			return nil, fmt.Errorf("Failed to create database for '%s', %w", dsn, err)
+		}
+
+		databases = append(databases, db.(*SQLiteFullTextDatabase))
+	}
+
+	ftdb := &SQLiteMultiFullTextDatabase{
+		databases: databases,
+	}
+
+	return ftdb, nil
+}
+
+func (ftdb *SQLiteMultiFullTextDatabase) Close(ctx context.Context) error {
+
+	var close_err error
+
+	for _, db := range ftdb.databases {
+
+		err := db.Close(ctx)
+
+		if err != nil && close_err == nil {
+			close_err = err
+		}
+	}
+
+	return close_err
+}
+
+// AddObserver registers 'o' with each of the underlying databases. It should be called before the database is used.
+func (ftdb *SQLiteMultiFullTextDatabase) AddObserver(o Observer) {
+
+	for _, db := range ftdb.databases {
+		db.AddObserver(o)
+	}
+}
+
+// IndexFeature is not supported by `SQLiteMultiFullTextDatabase` since there is no way to know which
+// of the underlying databases a feature should be written to.
+func (ftdb *SQLiteMultiFullTextDatabase) IndexFeature(ctx context.Context, f []byte) error {
+	return errors.New("Indexing features is not supported by sqlite-multi databases")
+}
+
+func (ftdb *SQLiteMultiFullTextDatabase) QueryString(ctx context.Context, term string, filters ...filter.Filter) (wof_spr.StandardPlacesResults, error) {
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, _, err := ftdb.QueryStringWithOptions(ctx, term, opts, filters...)
+	return r, err
+}
+
+// QueryStringWithOptions queries each of the underlying databases in parallel and merges their results. Records with the same
+// ID are de-duplicated, keeping the one with the most recent lastmodified date, and then ranked and paginated as a single set.
+// If 'opts' is nil the default query options are used.
+func (ftdb *SQLiteMultiFullTextDatabase) QueryStringWithOptions(ctx context.Context, term string, opts *QueryOptions, filters ...filter.Filter) (wof_spr.StandardPlacesResults, pagination.Results, error) {
+
+	if opts == nil {
+
+		default_opts, err := DefaultQueryOptions()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		opts = default_opts
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type queryResult struct {
+		Database int
+		Results  []*rankedResult
+	}
+
+	// Buffered so that the remaining queries don't block if we return early with an error
+
+	done_ch := make(chan bool, len(ftdb.databases))
+	err_ch := make(chan error, len(ftdb.databases))
+	rsp_ch := make(chan queryResult, len(ftdb.databases))
+
+	for idx, db := range ftdb.databases {
+
+		go func(idx int, db *SQLiteFullTextDatabase) {
+
+			defer func() {
+				done_ch <- true
+			}()
+
+			ranked, err := db.queryRanked(ctx, term, opts, filters...)
+
+			if err != nil {
+				err_ch <- fmt.Errorf("Failed to query %s, %w", db.db.DSN(), err)
+				return
+			}
+
+			rsp_ch <- queryResult{
+				Database: idx,
+				Results:  ranked,
+			}
+
+		}(idx, db)
+	}
+
+	results := make([][]*rankedResult, len(ftdb.databases))
+	remaining := len(ftdb.databases)
+
+	for remaining > 0 {
+		select {
+		case <-done_ch:
+			remaining -= 1
+		case err := <-err_ch:
+			return nil, nil, err
+		case rsp := <-rsp_ch:
+			results[rsp.Database] = rsp.Results
+		}
+	}
+
+	merged := mergeRankedResults(opts.Sort, results...)
+
+	page, pg, err := paginateRankedResults(merged, opts.Pagination)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := resultsFromRankedResults(ctx, merged, page, opts)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, pg, nil
+}
+
+// mergeRankedResults merges multiple lists of ranked results in to a single list sorted according to 'sorts' (or by relevance
+// if empty). Records with the same ID are de-duplicated keeping the record with the most recent lastmodified date.
+func mergeRankedResults(sorts []*SortOrder, results ...[]*rankedResult) []*rankedResult {
+
+	lookup := make(map[string]*rankedResult)
+	order := make([]string, 0)
+
+	for _, ranked := range results {
+
+		for _, r := range ranked {
+
+			id := r.SPR.Id()
+			current, exists := lookup[id]
+
+			if !exists {
+				lookup[id] = r
+				order = append(order, id)
+				continue
+			}
+
+			if r.SPR.LastModified() > current.SPR.LastModified() {
+				lookup[id] = r
+			}
+		}
+	}
+
+	merged := make([]*rankedResult, len(order))
+
+	for idx, id := range order {
+		merged[idx] = lookup[id]
+	}
+
+	sortRankedResults(merged, sorts...)
+	return merged
+}
+
+// expandDSNs expands any glob patterns in 'candidates' returning a list of unique DSN strings.
+func expandDSNs(candidates []string) ([]string, error) {
+
+	dsn_list := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, dsn := range candidates {
+
+		if dsn == "" {
+			continue
+		}
+
+		matches := []string{dsn}
+
+		if hasGlobPattern(dsn) {
+
+			m, err := filepath.Glob(dsn)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid glob pattern '%s', %w", dsn, err)
+			}
+
+			if len(m) == 0 {
+				return nil, fmt.Errorf("Glob pattern '%s' does not match any databases", dsn)
+			}
+
+			matches = m
+		}
+
+		for _, m := range matches {
+
+			if seen[m] {
+				continue
+			}
+
+			seen[m] = true
+			dsn_list = append(dsn_list, m)
+		}
+	}
+
+	return dsn_list, nil
+}
+
+func hasGlobPattern(path string) bool {
+
+	// SQLite URI filenames may contain query parameters
+	if strings.HasPrefix(path, "file:") {
+		return false
+	}
+
+	for _, c := range path {
+
+		switch c {
+		case '*', '?', '[':
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-pagination/countable"
+	"path/filepath"
+	"testing"
+)
+
+// newTestMultiDatabase returns a new `SQLiteMultiFullTextDatabase` instance for two databases, in a temporary directory, that have
+// indexed the features returned by `multiFeatures`. It also returns the second of the underlying databases.
+func newTestMultiDatabase(t *testing.T) (*SQLiteMultiFullTextDatabase, *SQLiteFullTextDatabase) {
+
+	t.Helper()
+
+	ctx := context.Background()
+
+	features_a, features_b := multiFeatures()
+
+	root := t.TempDir()
+
+	dsn_a := filepath.Join(root, "a.db")
+	dsn_b := filepath.Join(root, "b.db")
+
+	newTestDatabaseWithDSN(t, dsn_a, "", features_a...)
+	ftdb_b := newTestDatabaseWithDSN(t, dsn_b, "", features_b...)
+
+	uri := fmt.Sprintf("sqlite-multi://?dsn=%s&dsn=%s", dsn_a, dsn_b)
+
+	db, err := NewSQLiteMultiFullTextDatabase(ctx, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create database for %s, %v", uri, err)
+	}
+
+	t.Cleanup(func() {
+		db.Close(ctx)
+	})
+
+	return db.(*SQLiteMultiFullTextDatabase), ftdb_b
+}
+
+// multiFeatures returns the features for two databases which both contain record 1, most recently modified in the second.
+func multiFeatures() ([][]byte, [][]byte) {
+
+	features_a := [][]byte{
+		testFeature(1, "Paris", 48.86, 2.35, map[string]interface{}{
+			"wof:country":      "XX",
+			"wof:lastmodified": 1600000000,
+		}),
+		testFeature(2, "Paris Hill", 44.25, -70.50, nil),
+	}
+
+	features_b := [][]byte{
+		testFeature(1, "Paris", 48.86, 2.35, map[string]interface{}{
+			"wof:country":      "FR",
+			"wof:lastmodified": 1700000000,
+		}),
+		testFeature(3, "Little Paris", 30.00, -90.00, nil),
+	}
+
+	return features_a, features_b
+}
+
+// queryMultiIds returns the IDs of the results for 'term', in the order they are returned, using 'opts'.
+func queryMultiIds(t *testing.T, ftdb *SQLiteMultiFullTextDatabase, term string, opts *QueryOptions) []string {
+
+	t.Helper()
+
+	r, _, err := ftdb.QueryStringWithOptions(context.Background(), term, opts)
+
+	if err != nil {
+		t.Fatalf("Failed to query '%s', %v", term, err)
+	}
+
+	ids := make([]string, 0)
+
+	for _, s := range r.Results() {
+		ids = append(ids, s.Id())
+	}
+
+	return ids
+}
+
+func TestMultiMerge(t *testing.T) {
+
+	ftdb, _ := newTestMultiDatabase(t)
+
+	r, _, err := ftdb.QueryStringWithOptions(context.Background(), "paris", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to query, %v", err)
+	}
+
+	ids := make([]string, 0)
+
+	for _, s := range r.Results() {
+
+		ids = append(ids, s.Id())
+
+		if s.Id() != "1" {
+			continue
+		}
+
+		if s.Country() != "FR" || s.LastModified() != 1700000000 {
+			t.Errorf("Expected record 1 to be the most recently modified copy but got %s, %d", s.Country(), s.LastModified())
+		}
+	}
+
+	// Record 1 is returned once, followed by the prefix and substring matches from each database
+
+	expected := []string{"1", "2", "3"}
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected results %v but got %v", expected, ids)
+	}
+}
+
+func TestMultiSortAndPaginate(t *testing.T) {
+
+	ftdb, _ := newTestMultiDatabase(t)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	sorts, err := ParseSortOrders("-id", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to parse sort orders, %v", err)
+	}
+
+	opts.Sort = sorts
+
+	ids := queryMultiIds(t, ftdb, "paris", opts)
+	expected := []string{"3", "2", "1"}
+
+	if !equalIds(ids, expected) {
+		t.Fatalf("Expected results sorted by descending ID %v but got %v", expected, ids)
+	}
+
+	pg_opts, err := countable.NewCountableOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create pagination options, %v", err)
+	}
+
+	pg_opts.PerPage(2)
+	pg_opts.Pointer(int64(2))
+
+	opts.Pagination = pg_opts
+
+	ids = queryMultiIds(t, ftdb, "paris", opts)
+	expected = []string{"1"}
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected the second page of results to be %v but got %v", expected, ids)
+	}
+}
+
+func TestMultiQueryError(t *testing.T) {
+
+	ftdb, ftdb_b := newTestMultiDatabase(t)
+
+	conn, err := ftdb_b.db.Conn()
+
+	if err != nil {
+		t.Fatalf("Failed to create database connection, %v", err)
+	}
+
+	_, err = conn.Exec(fmt.Sprintf("DROP TABLE %s", ftdb_b.search_table.Name()))
+
+	if err != nil {
+		t.Fatalf("Failed to drop search table, %v", err)
+	}
+
+	// A query fails if any one of the underlying databases fails, rather than returning partial results
+
+	_, _, err = ftdb.QueryStringWithOptions(context.Background(), "paris", nil)
+
+	if err == nil {
+		t.Errorf("Expected query to fail when one of the databases can not be queried")
+	}
+}
@@ -0,0 +1,302 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+	"github.com/whosonfirst/go-whosonfirst-names/tags"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NormalizationRules defines how the punctuation and numeric ordinals in names written in a given language are normalized.
+type NormalizationRules struct {
+	// The word that "&" is replaced with, for example "and".
+	Ampersand string
+	// Whether an apostrophe marks an elided word, for example "l'" in "L'Assomption", in which case it separates words. Otherwise
+	// apostrophes are removed, so "St John's" becomes "st johns".
+	Elision bool
+	// The (lower case, unaccented) suffixes that follow the digits of a numeric ordinal, for example "st" in "1st". Ordinals are
+	// replaced with their digits.
+	OrdinalSuffixes []string
+}
+
+// normalizationTables are the `NormalizationRules` for each (ISO 639-3) language.
+var normalizationTables = map[string]*NormalizationRules{
+	"cat": &NormalizationRules{
+		Ampersand:       "i",
+		Elision:         true,
+		OrdinalSuffixes: []string{"r", "n", "t", "e", "a", "º", "ª"},
+	},
+	"deu": &NormalizationRules{
+		Ampersand:       "und",
+		OrdinalSuffixes: []string{"."},
+	},
+	"eng": &NormalizationRules{
+		Ampersand:       "and",
+		OrdinalSuffixes: []string{"st", "nd", "rd", "th"},
+	},
+	"fra": &NormalizationRules{
+		Ampersand:       "et",
+		Elision:         true,
+		OrdinalSuffixes: []string{"er", "re", "ere", "e", "eme", "d", "de", "nd", "nde"},
+	},
+	"ita": &NormalizationRules{
+		Ampersand:       "e",
+		Elision:         true,
+		OrdinalSuffixes: []string{"o", "a", "º", "ª"},
+	},
+	"nld": &NormalizationRules{
+		Ampersand:       "en",
+		OrdinalSuffixes: []string{"e", "ste", "de"},
+	},
+	"por": &NormalizationRules{
+		Ampersand:       "e",
+		OrdinalSuffixes: []string{"o", "a", "º", "ª"},
+	},
+	"spa": &NormalizationRules{
+		Ampersand:       "y",
+		OrdinalSuffixes: []string{"o", "a", "er", "ra", "º", "ª"},
+	},
+}
+
+var re_initialism = regexp.MustCompile(`(^|[^\pL\pN])((?:\pL\.){2,})`)
+
+var re_ordinal = regexp.MustCompile(`^(\d+)(\D+)$`)
+
+// Characters, other than "-", that are treated as hyphens.
+const hyphenRunes string = "‐‑‒–—―−"
+
+// Characters, other than "'", that are treated as apostrophes.
+const apostropheRunes string = "’‘ʼʻ`´"
+
+// NormalizeName returns 'name' in lower case, with diacritics removed from Latin letters, and its punctuation and numeric ordinals
+// normalized according to the `NormalizationRules` for 'lang', which is an ISO 639-3 language code. Hyphens are replaced with spaces,
+// initialisms lose their periods ("N.Y." becomes "ny") and other punctuation separates words. If there are no rules for 'lang'
+// apostrophes separate words, "&" is removed and ordinals are left as-is. For example "Montréal-Est" becomes "montreal est" and
+// "1er Arrondissement" becomes "1 arrondissement" in French.
+func NormalizeName(name string, lang string) string {
+
+	rules, ok := normalizationTables[strings.ToLower(lang)]
+
+	if !ok {
+		rules = &NormalizationRules{
+			Elision: true,
+		}
+	}
+
+	name = foldName(strings.ToLower(name))
+
+	name = strings.Map(func(r rune) rune {
+
+		switch {
+		case strings.ContainsRune(hyphenRunes, r):
+			return '-'
+		case strings.ContainsRune(apostropheRunes, r):
+			return '\''
+		default:
+			return r
+		}
+
+	}, name)
+
+	name = strings.ReplaceAll(name, "-", " ")
+	name = strings.ReplaceAll(name, "&", fmt.Sprintf(" %s ", rules.Ampersand))
+
+	if rules.Elision {
+		name = strings.ReplaceAll(name, "'", " ")
+	} else {
+		name = strings.ReplaceAll(name, "'", "")
+	}
+
+	name = re_initialism.ReplaceAllStringFunc(name, func(m string) string {
+		return strings.ReplaceAll(m, ".", "")
+	})
+
+	words := make([]string, 0)
+
+	for _, w := range strings.Fields(name) {
+
+		w = normalizeOrdinal(w, rules.OrdinalSuffixes)
+
+		words = append(words, strings.FieldsFunc(w, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})...)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// normalizeOrdinal returns the digits of 'word' if it is a numeric ordinal ending with any of 'suffixes', ignoring any trailing
+// period, for example "1st" or "1er". Otherwise 'word' is returned unchanged.
+func normalizeOrdinal(word string, suffixes []string) string {
+
+	m := re_ordinal.FindStringSubmatch(word)
+
+	if m == nil {
+		return word
+	}
+
+	suffix := m[2]
+
+	if suffix != "." {
+		suffix = strings.TrimSuffix(suffix, ".")
+	}
+
+	if !stringInList(suffix, suffixes) {
+		return word
+	}
+
+	return m[1]
+}
+
+// normalizedForms returns the distinct normalized forms (see `NormalizeName`) of 'name' for each of 'languages' or, if empty, for
+// every language with `NormalizationRules`.
+func normalizedForms(name string, languages ...string) []string {
+
+	if len(languages) == 0 {
+		languages = normalizationLanguages()
+	}
+
+	forms := make([]string, 0)
+
+	for _, lang := range languages {
+
+		form := NormalizeName(name, lang)
+
+		if form != "" && !stringInList(form, forms) {
+			forms = append(forms, form)
+		}
+	}
+
+	return forms
+}
+
+// normalizationLanguages returns the sorted list of languages with `NormalizationRules`.
+func normalizationLanguages() []string {
+
+	languages := make([]string, 0)
+
+	for lang, _ := range normalizationTables {
+		languages = append(languages, lang)
+	}
+
+	sort.Strings(languages)
+	return languages
+}
+
+// normalizationExpander returns a `tokenExpander` that adds the normalized forms of search terms, in every language, so that
+// "Montréal-Est" also matches "montreal est" and "1er" also matches "1".
+func normalizationExpander() tokenExpander {
+
+	return func(token string) []string {
+
+		lower := strings.ToLower(token)
+		expanded := make([]string, 0)
+
+		for _, form := range normalizedForms(token) {
+
+			if form != lower {
+				expanded = append(expanded, form)
+			}
+		}
+
+		return expanded
+	}
+}
+
+// featureNormalizedForms returns the normalized forms of the (default and language-specific) names of 'f'. Names are normalized
+// according to the language of their tag and the default name, whose language isn't known, for every language.
+func featureNormalizedForms(f []byte) ([]string, error) {
+
+	name, err := properties.Name(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	forms := normalizedForms(name)
+
+	for tag, tag_names := range properties.Names(f) {
+
+		lt, err := tags.NewLangTag(tag)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create new lang tag for '%s', %w", tag, err)
+		}
+
+		var languages []string
+
+		_, ok := normalizationTables[lt.Language()]
+
+		if ok {
+			languages = []string{lt.Language()}
+		}
+
+		for _, n := range tag_names {
+
+			for _, form := range normalizedForms(n, languages...) {
+
+				if !stringInList(form, forms) {
+					forms = append(forms, form)
+				}
+			}
+		}
+	}
+
+	sort.Strings(forms)
+	return forms, nil
+}
+
+// indexNormalizedNames appends the normalized forms of the names of 'f' to the names indexed in the search table.
+func (ftdb *SQLiteFullTextDatabase) indexNormalizedNames(ctx context.Context, id int64, f []byte) error {
+
+	forms, err := featureNormalizedForms(f)
+
+	if err != nil {
+		return err
+	}
+
+	expand := func(names string) []string {
+
+		// Compare whole tokens, as split by the full-text tokenizer, so that "montreal est" isn't added to "Montreal-Est"
+		words := fmt.Sprintf(" %s ", strings.Join(fullTextTokens(names), " "))
+		expanded := make([]string, 0)
+
+		for _, form := range forms {
+
+			if !strings.Contains(words, fmt.Sprintf(" %s ", form)) {
+				expanded = append(expanded, form)
+			}
+		}
+
+		return expanded
+	}
+
+	return ftdb.appendIndexedNames(ctx, id, expand)
+}
+
+// fullTextTokens returns the tokens in 'str' as they are split by the (simple) full-text tokenizer, which treats every character that
+// isn't an ASCII letter or number, other than non-ASCII characters, as a separator and only lower-cases ASCII letters.
+func fullTextTokens(str string) []string {
+
+	tokens := strings.FieldsFunc(str, func(r rune) bool {
+		return r <= unicode.MaxASCII && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9')
+	})
+
+	for i, t := range tokens {
+
+		tokens[i] = strings.Map(func(r rune) rune {
+
+			if r >= 'A' && r <= 'Z' {
+				return r + ('a' - 'A')
+			}
+
+			return r
+		}, t)
+	}
+
+	return tokens
+}
@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+
+	tests := []struct {
+		Name     string
+		Lang     string
+		Expected string
+	}{
+		// Hyphens, including the non-ASCII dashes in hyphenRunes
+		{Name: "Montréal-Est", Lang: "fra", Expected: "montreal est"},
+		{Name: "Saint‐Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Saint‑Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Saint‒Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Saint–Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Saint—Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Saint―Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Saint−Jean", Lang: "fra", Expected: "saint jean"},
+		{Name: "Stratford-upon-Avon", Lang: "eng", Expected: "stratford upon avon"},
+		{Name: "Castrop-Rauxel", Lang: "deu", Expected: "castrop rauxel"},
+		// Apostrophes, which mark an elision in some languages and are otherwise removed
+		{Name: "L'Assomption", Lang: "fra", Expected: "l assomption"},
+		{Name: "L’Assomption", Lang: "fra", Expected: "l assomption"},
+		{Name: "L'Aquila", Lang: "ita", Expected: "l aquila"},
+		{Name: "L'Hospitalet", Lang: "cat", Expected: "l hospitalet"},
+		{Name: "St John's", Lang: "eng", Expected: "st johns"},
+		{Name: "St John’s", Lang: "eng", Expected: "st johns"},
+		{Name: "'s-Hertogenbosch", Lang: "nld", Expected: "s hertogenbosch"},
+		// Initialisms
+		{Name: "N.Y.", Lang: "eng", Expected: "ny"},
+		{Name: "Washington, D.C.", Lang: "eng", Expected: "washington dc"},
+		{Name: "St. Louis", Lang: "eng", Expected: "st louis"},
+		// Ampersands
+		{Name: "Trinidad & Tobago", Lang: "eng", Expected: "trinidad and tobago"},
+		{Name: "Trois & Rivières", Lang: "fra", Expected: "trois et rivieres"},
+		{Name: "Stadt & Land", Lang: "deu", Expected: "stadt und land"},
+		{Name: "Castilla & León", Lang: "spa", Expected: "castilla y leon"},
+		{Name: "Sant Pere & Sant Pau", Lang: "cat", Expected: "sant pere i sant pau"},
+		{Name: "Bosnia & Erzegovina", Lang: "ita", Expected: "bosnia e erzegovina"},
+		{Name: "Land & Zee", Lang: "nld", Expected: "land en zee"},
+		{Name: "São Tomé & Príncipe", Lang: "por", Expected: "sao tome e principe"},
+		// Ordinals
+		{Name: "1st Arrondissement", Lang: "eng", Expected: "1 arrondissement"},
+		{Name: "22nd Ward", Lang: "eng", Expected: "22 ward"},
+		{Name: "3rd District", Lang: "eng", Expected: "3 district"},
+		{Name: "4th Avenue", Lang: "eng", Expected: "4 avenue"},
+		{Name: "1er Arrondissement", Lang: "fra", Expected: "1 arrondissement"},
+		{Name: "2e Arrondissement", Lang: "fra", Expected: "2 arrondissement"},
+		{Name: "3ème Arrondissement", Lang: "fra", Expected: "3 arrondissement"},
+		{Name: "1. Bezirk", Lang: "deu", Expected: "1 bezirk"},
+		{Name: "2º Distrito", Lang: "spa", Expected: "2 distrito"},
+		{Name: "1er Distrito", Lang: "spa", Expected: "1 distrito"},
+		{Name: "3º Municipio", Lang: "ita", Expected: "3 municipio"},
+		{Name: "1e Wijk", Lang: "nld", Expected: "1 wijk"},
+		{Name: "2º Distrito", Lang: "por", Expected: "2 distrito"},
+		// Suffixes are only removed in the languages they belong to
+		{Name: "1er Arrondissement", Lang: "eng", Expected: "1er arrondissement"},
+		{Name: "1st Arrondissement", Lang: "fra", Expected: "1st arrondissement"},
+		// Languages without rules, in which apostrophes separate words, "&" is removed and ordinals are left as-is
+		{Name: "Saint-Jean & Saint-Paul", Lang: "xyz", Expected: "saint jean saint paul"},
+		{Name: "L'Île-Perrot", Lang: "", Expected: "l ile perrot"},
+		{Name: "1st Street", Lang: "jpn", Expected: "1st street"},
+		// Language codes are not case-sensitive
+		{Name: "Trois & Rivières", Lang: "FRA", Expected: "trois et rivieres"},
+	}
+
+	for _, test := range tests {
+
+		v := NormalizeName(test.Name, test.Lang)
+
+		if v != test.Expected {
+			t.Errorf("Expected '%s' (%s) to be normalized as '%s' but got '%s'", test.Name, test.Lang, test.Expected, v)
+		}
+	}
+}
+
+func TestNormalizedNameQueries(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(1, "Montréal-Est", 45.63, -73.52, nil),
+		testFeature(2, "Montreal West", 45.45, -73.65, nil),
+		testFeature(3, "L’Assomption", 45.82, -73.42, nil),
+		testFeature(4, "Paris 1er Arrondissement", 48.86, 2.34, map[string]interface{}{
+			"name:eng_x_preferred": []string{"1st Arrondissement of Paris"},
+		}),
+	}
+
+	ftdb := newTestDatabase(t, "normalize=true", features...)
+
+	tests := []struct {
+		Term     string
+		Expected []string
+	}{
+		{Term: "Montreal-Est", Expected: []string{"1"}},
+		{Term: "Montreal Est", Expected: []string{"1"}},
+		{Term: "Montréal Est", Expected: []string{"1"}},
+		{Term: "montreal–est", Expected: []string{"1"}},
+		{Term: "L'Assomption", Expected: []string{"3"}},
+		{Term: "Assomption", Expected: []string{"3"}},
+		{Term: "1st Arrondissement", Expected: []string{"4"}},
+		{Term: "1er arrondissement", Expected: []string{"4"}},
+	}
+
+	for _, test := range tests {
+
+		ids := sortedIds(queryIds(t, ftdb, test.Term, nil))
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected '%s' to match %v but got %v", test.Term, test.Expected, ids)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// QueryEvent describes a single query performed against a SQLite database.
+type QueryEvent struct {
+	// The DSN of the database that was queried.
+	Database string
+	// The search term being queried.
+	Term string
+	// The SQL statement used to find matching records.
+	SQL string
+	// The full-text MATCH expression used by SQL.
+	Match string
+	// The full-text MATCH expression for the phonetic codes of the search term, if matched phonetically.
+	PhoneticMatch string
+	// Descriptions of the filters applied by SQL.
+	SQLFilters []string
+	// Descriptions of the filters applied to the results of SQL.
+	GoFilters []string
+	// The number of rows matched by SQL.
+	RowsMatched int
+	// The number of results remaining after filtering.
+	Results int
+	// The time spent retrieving SPR records for the rows matched by SQL.
+	SPRFetchTime time.Duration
+	// The total time spent performing the query.
+	Latency time.Duration
+	// The time spent in each stage of the query, in the order they were performed.
+	Stages []*QueryStage
+	// Any error that was triggered performing the query.
+	Error error
+	// Whether the results were read from the query cache, in which case no SQL was performed and only Term, Results and Latency
+	// are set.
+	Cached bool
+	// The arguments for SQL, used to generate EXPLAIN QUERY PLAN output.
+	args []interface{}
+}
+
+// QueryStage records the time spent in a single stage of a query.
+type QueryStage struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (ev *QueryEvent) addStage(name string, t1 time.Time) {
+
+	st := &QueryStage{
+		Name:     name,
+		Duration: time.Since(t1),
+	}
+
+	ev.Stages = append(ev.Stages, st)
+}
+
+// IndexEvent describes a single feature being indexed in a SQLite database.
+type IndexEvent struct {
+	// The DSN of the database being indexed.
+	Database string
+	// The Who's On First ID of the feature being indexed.
+	Id int64
+	// The total time spent indexing the feature.
+	Latency time.Duration
+	// Any error that was triggered indexing the feature.
+	Error error
+}
+
+// Observer is an interface for receiving events about the operations performed by a `SQLiteFullTextDatabase` instance.
+// Implementations are called synchronously and should return quickly.
+type Observer interface {
+	ObserveQuery(context.Context, *QueryEvent)
+	ObserveIndex(context.Context, *IndexEvent)
+}
+
+// SlowQueryLogger implements the `Observer` interface and logs queries and index operations that take longer than a given threshold.
+type SlowQueryLogger struct {
+	Observer
+	logger    *log.Logger
+	threshold time.Duration
+}
+
+// NewSlowQueryLogger returns a new `SlowQueryLogger` instance that will log events that take longer than 'threshold' to 'logger'.
+func NewSlowQueryLogger(logger *log.Logger, threshold time.Duration) *SlowQueryLogger {
+
+	o := &SlowQueryLogger{
+		logger:    logger,
+		threshold: threshold,
+	}
+
+	return o
+}
+
+func (o *SlowQueryLogger) ObserveQuery(ctx context.Context, ev *QueryEvent) {
+
+	if ev.Latency < o.threshold {
+		return
+	}
+
+	o.logger.Printf("Slow query for '%s' in %s took %v (%d rows matched, %d results, %v fetching SPRs, error: %v) SQL: %s", ev.Term, ev.Database, ev.Latency, ev.RowsMatched, ev.Results, ev.SPRFetchTime, ev.Error, ev.SQL)
+}
+
+func (o *SlowQueryLogger) ObserveIndex(ctx context.Context, ev *IndexEvent) {
+
+	if ev.Latency < o.threshold {
+		return
+	}
+
+	o.logger.Printf("Slow index operation for %d in %s took %v (error: %v)", ev.Id, ev.Database, ev.Latency, ev.Error)
+}
@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"github.com/aaronland/go-pagination"
+)
+
+// QueryOptions defines options for refining the results returned by the `QueryStringWithOptions` method.
+type QueryOptions struct {
+	// An optional pagination.Options instance used to limit the results returned. If nil all the results are returned.
+	Pagination pagination.Options
+	// MatchMode defines how search terms are converted in to full-text MATCH expressions. Default is `PlainTextMatch`.
+	MatchMode MatchMode
+	// An optional LabelOptions instance used to add a human-readable label, derived from the names of its ancestors, to each result.
+	// If nil results are not labelled.
+	Labels *LabelOptions
+	// An optional list of facets (see `AllFacets`) to count across all the results matching a query, not just those in the current page.
+	Facets []string
+	// The order in which results are returned. If empty results are returned in descending order of relevance. Orders that do
+	// not include `SortByRelevance` are applied in SQL.
+	Sort []*SortOrder
+	// An optional list of property paths (see `ValidatePropertyPath`), for example "wof:population", whose values are read from the
+	// properties table and added to each result in the current page.
+	Extras []string
+	// An optional list of (ISO 639-3) language codes, for example "eng" or "fra", whose synonyms are used to expand plain text search
+	// terms. If empty the synonyms for all the languages in the database's synonym dictionary are used.
+	SynonymLanguages []string
+	// If true, plain text search terms are also split in to the name of a place and the names of one or more of its ancestors, for
+	// example "paris texas" or "London, Ontario", and the places whose ancestors match are added to the results (see `qualifiedSplits`).
+	// The ancestors table must be present in the database. Default is false.
+	Hierarchical bool
+	// An optional ImportanceWeights instance used to boost the relevance of important records, for example populous or current places,
+	// over otherwise equally relevant records. If nil results are ranked by relevance alone.
+	Importance *ImportanceWeights
+	// An optional FocusPoint instance used to boost the relevance of records near a point, for example the position of a user,
+	// without excluding distant records. If nil the location of records does not affect their relevance.
+	Focus *FocusPoint
+}
+
+// DefaultQueryOptions returns a new QueryOptions instance that will return all the results for a query.
+func DefaultQueryOptions() (*QueryOptions, error) {
+
+	opts := &QueryOptions{
+		MatchMode: PlainTextMatch,
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"errors"
+	"github.com/aaronland/go-pagination"
+	"github.com/aaronland/go-pagination/countable"
+	"math"
+)
+
+// paginateRankedResults returns the subset of 'ranked' defined by 'pg_opts' as well as a pagination.Results instance
+// describing that subset. If 'pg_opts' is nil then all the results are returned and the pagination.Results instance is nil.
+func paginateRankedResults(ranked []*rankedResult, pg_opts pagination.Options) ([]*rankedResult, pagination.Results, error) {
+
+	if pg_opts == nil {
+		return ranked, nil, nil
+	}
+
+	if pg_opts.Method() != pagination.Countable {
+		return nil, nil, errors.New("Unsupported pagination method")
+	}
+
+	total := int64(len(ranked))
+
+	pg, err := countable.NewResultsFromCountWithOptions(pg_opts, total)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := int64(math.Max(1.0, float64(countable.PageFromOptions(pg_opts))))
+	per_page := pg.PerPage()
+
+	start := (page - 1) * per_page
+	end := start + per_page
+
+	if start > total {
+		start = total
+	}
+
+	if end > total {
+		end = total
+	}
+
+	return ranked[start:end], pg, nil
+}
@@ -0,0 +1,401 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	aa_sqlite "github.com/aaronland/go-sqlite"
+	"strings"
+)
+
+// The relevance of results that are only matched by the phonetic codes of their names. It is lower than the relevance of
+// any result matched by its names (see `relevance`) so that exact matches are always ranked first.
+const phoneticScore float64 = 0.1
+
+// Metaphone returns the Metaphone code for 'word', for example "XKK" for both "Chicago" and "Shicago". Letters with diacritics
+// are folded to their base letters first and anything else that isn't a Latin letter is ignored. An empty string is returned if
+// 'word' does not contain any Latin letters.
+//
+// The rules are those of Lawrence Philips' original Metaphone algorithm with one addition: a "C" followed by an "S" is silent,
+// as in "Tucson", since it is almost never pronounced in place names.
+func Metaphone(word string) string {
+
+	letters := make([]byte, 0)
+
+	for _, r := range foldName(strings.ToLower(word)) {
+
+		if r >= 'a' && r <= 'z' {
+			letters = append(letters, byte(r-'a'+'A'))
+		}
+	}
+
+	if len(letters) == 0 {
+		return ""
+	}
+
+	w := string(letters)
+
+	// Initial letter exceptions
+
+	switch {
+	case strings.HasPrefix(w, "AE"), strings.HasPrefix(w, "GN"), strings.HasPrefix(w, "KN"), strings.HasPrefix(w, "PN"), strings.HasPrefix(w, "WR"):
+		w = w[1:]
+	case strings.HasPrefix(w, "X"):
+		w = "S" + w[1:]
+	case strings.HasPrefix(w, "WH"):
+		w = "W" + w[2:]
+	}
+
+	at := func(i int) byte {
+
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+
+		return w[i]
+	}
+
+	is_vowel := func(c byte) bool {
+		return c != 0 && strings.IndexByte("AEIOU", c) != -1
+	}
+
+	is_front := func(c byte) bool {
+		return c != 0 && strings.IndexByte("EIY", c) != -1
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(w); i++ {
+
+		c := w[i]
+		prev := at(i - 1)
+		next := at(i + 1)
+
+		// Double letters, other than "CC", are treated as one
+		if c == prev && c != 'C' {
+			continue
+		}
+
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+
+			if i == 0 {
+				sb.WriteByte(c)
+			}
+
+		case 'B':
+
+			// Silent at the end of a word after "M", as in "Plumb"
+			if !(prev == 'M' && i == len(w)-1) {
+				sb.WriteByte('B')
+			}
+
+		case 'C':
+
+			switch {
+			case next == 'I' && at(i+2) == 'A':
+				sb.WriteByte('X')
+			case next == 'H':
+
+				if prev == 'S' {
+					sb.WriteByte('K')
+				} else {
+					sb.WriteByte('X')
+				}
+
+				i += 1
+
+			case is_front(next):
+
+				if prev != 'S' {
+					sb.WriteByte('S')
+				}
+
+			case next == 'S', next == 'K':
+				// pass
+			default:
+				sb.WriteByte('K')
+			}
+
+		case 'D':
+
+			if next == 'G' && is_front(at(i+2)) {
+				sb.WriteByte('J')
+				i += 1
+			} else {
+				sb.WriteByte('T')
+			}
+
+		case 'G':
+
+			switch {
+			case next == 'H' && i+2 < len(w) && !is_vowel(at(i+2)):
+				// Silent as in "Knight"
+			case next == 'N' && (i+2 == len(w) || (at(i+2) == 'E' && at(i+3) == 'D' && i+4 == len(w))):
+				// Silent as in "Sign" and "Signed"
+			case is_front(next) && prev != 'G':
+				sb.WriteByte('J')
+			default:
+				sb.WriteByte('K')
+			}
+
+		case 'H':
+
+			switch {
+			case prev != 0 && strings.IndexByte("CSPTG", prev) != -1:
+				// Part of a digraph
+			case is_vowel(prev) && !is_vowel(next):
+				// Silent as in "Utah"
+			default:
+				sb.WriteByte('H')
+			}
+
+		case 'K':
+
+			if prev != 'C' {
+				sb.WriteByte('K')
+			}
+
+		case 'P':
+
+			if next == 'H' {
+				sb.WriteByte('F')
+			} else {
+				sb.WriteByte('P')
+			}
+
+		case 'Q':
+			sb.WriteByte('K')
+
+		case 'S':
+
+			switch {
+			case next == 'H':
+				sb.WriteByte('X')
+			case next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				sb.WriteByte('X')
+			default:
+				sb.WriteByte('S')
+			}
+
+		case 'T':
+
+			switch {
+			case next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				sb.WriteByte('X')
+			case next == 'H':
+				sb.WriteByte('0')
+			case next == 'C' && at(i+2) == 'H':
+				// Silent as in "Fitch"
+			default:
+				sb.WriteByte('T')
+			}
+
+		case 'V':
+			sb.WriteByte('F')
+
+		case 'W', 'Y':
+
+			if is_vowel(next) {
+				sb.WriteByte(c)
+			}
+
+		case 'X':
+			sb.WriteString("KS")
+
+		case 'Z':
+			sb.WriteByte('S')
+
+		default:
+			// F, J, L, M, N and R
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// phoneticCodes returns the distinct Metaphone codes for the Latin words in 'str'. Words in other scripts are ignored.
+func phoneticCodes(str string) []string {
+
+	codes := make([]string, 0)
+
+	for _, w := range splitSynonymWords(str) {
+
+		if detectScript(w) != "Latn" {
+			continue
+		}
+
+		code := Metaphone(w)
+
+		if code != "" && !stringInList(code, codes) {
+			codes = append(codes, code)
+		}
+	}
+
+	return codes
+}
+
+// phoneticExpression returns the full-text MATCH expression for the phonetic codes of 'term', requiring all of them to
+// match. It returns an empty string if 'term' does not contain any Latin words.
+func phoneticExpression(term string) string {
+
+	codes := phoneticCodes(term)
+	phrases := make([]string, len(codes))
+
+	for i, c := range codes {
+		phrases[i] = fmt.Sprintf(`"%s"`, c)
+	}
+
+	return strings.Join(phrases, " ")
+}
+
+// indexPhonetics replaces the phonetic codes for 'id' with those of the names indexed for it in the search table, including any
+// transliterations or synonyms that have already been added.
+func (ftdb *SQLiteFullTextDatabase) indexPhonetics(ctx context.Context, id int64) error {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return err
+	}
+
+	_, names, found, err := ftdb.indexedNames(ctx, conn, id)
+
+	if err != nil {
+		return err
+	}
+
+	// Alternate geometries are not indexed in the search table
+	if !found {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	err = ftdb.phonetics_table.(*phoneticsTable).replaceCodes(ctx, tx, id, phoneticCodes(names))
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// namesMatches returns the IDs of the records whose names or ID, rather than their phonetic codes, are matched by the MATCH expression 'match'.
+func (ftdb *SQLiteFullTextDatabase) namesMatches(ctx context.Context, conn *sql.DB, match string) (map[int64]bool, error) {
+
+	q := fmt.Sprintf("SELECT id FROM %s WHERE names_all MATCH ? OR id MATCH ?", ftdb.search_table.Name())
+
+	rows, err := conn.QueryContext(ctx, q, match, match)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	matches := make(map[int64]bool)
+
+	for rows.Next() {
+
+		var id int64
+
+		err := rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		matches[id] = true
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// phoneticsTable implements the `aa_sqlite.Table` interface for the phonetic codes of the names of each record. It is an
+// fts4 table whose docid is the record's ID.
+type phoneticsTable struct {
+	aa_sqlite.Table
+	name string
+}
+
+// newPhoneticsTableWithDatabase returns a new `phoneticsTable` instance, creating the table in 'db' if necessary.
+func newPhoneticsTableWithDatabase(ctx context.Context, db aa_sqlite.Database) (aa_sqlite.Table, error) {
+
+	t, err := newPhoneticsTable(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.InitializeTable(ctx, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// newPhoneticsTable returns a new `phoneticsTable` instance.
+func newPhoneticsTable(ctx context.Context) (aa_sqlite.Table, error) {
+
+	t := &phoneticsTable{
+		name: "phonetics",
+	}
+
+	return t, nil
+}
+
+func (t *phoneticsTable) Name() string {
+	return t.name
+}
+
+func (t *phoneticsTable) Schema() string {
+	return fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts4(codes);", t.Name())
+}
+
+func (t *phoneticsTable) InitializeTable(ctx context.Context, db aa_sqlite.Database) error {
+	return aa_sqlite.CreateTableIfNecessary(ctx, db, t)
+}
+
+// IndexRecord is not supported since phonetic codes are derived from the names indexed in the search table rather than from
+// a feature (see `indexPhonetics`).
+func (t *phoneticsTable) IndexRecord(ctx context.Context, db aa_sqlite.Database, i interface{}) error {
+	return fmt.Errorf("Phonetic codes are indexed by the full-text database")
+}
+
+func (t *phoneticsTable) replaceCodes(ctx context.Context, tx *sql.Tx, id int64, codes []string) error {
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE docid = ?", t.Name()), id)
+
+	if err != nil {
+		return err
+	}
+
+	if len(codes) == 0 {
+		return nil
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (docid, codes) VALUES (?, ?)", t.Name())
+
+	_, err = tx.ExecContext(ctx, q, id, strings.Join(codes, " "))
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMetaphone(t *testing.T) {
+
+	tests := []struct {
+		Word     string
+		Expected string
+	}{
+		{Word: "Chicago", Expected: "XKK"},
+		{Word: "Shicago", Expected: "XKK"},
+		{Word: "Philadelphia", Expected: "FLTLF"},
+		{Word: "Filadelfia", Expected: "FLTLF"},
+		// A "C" followed by an "S" is silent
+		{Word: "Tucson", Expected: "TSN"},
+		{Word: "Tuson", Expected: "TSN"},
+		{Word: "Wright", Expected: "RT"},
+		{Word: "Rite", Expected: "RT"},
+		// Diacritics are folded
+		{Word: "Zürich", Expected: "SRX"},
+		{Word: "Zurich", Expected: "SRX"},
+		// Words without any Latin letters don't have a code
+		{Word: "123", Expected: ""},
+		{Word: "", Expected: ""},
+	}
+
+	for _, test := range tests {
+
+		code := Metaphone(test.Word)
+
+		if code != test.Expected {
+			t.Errorf("Expected the Metaphone code for '%s' to be '%s' but got '%s'", test.Word, test.Expected, code)
+		}
+	}
+}
+
+func TestPhoneticRanking(t *testing.T) {
+
+	ctx := context.Background()
+
+	features := [][]byte{
+		testFeature(1, "Shicago", 41.88, -87.63, nil),
+		testFeature(2, "Chicago", 41.88, -87.63, nil),
+		testFeature(3, "Chicago Heights", 41.51, -87.64, nil),
+	}
+
+	ftdb := newTestDatabase(t, "phonetic=true", features...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	opts.MatchMode = PhoneticMatch
+
+	ranked, err := ftdb.queryRanked(ctx, "chicago", opts)
+
+	if err != nil {
+		t.Fatalf("Failed to query, %v", err)
+	}
+
+	scores := make(map[string]float64)
+
+	for _, r := range ranked {
+		scores[r.SPR.Id()] = r.Score
+	}
+
+	if scores["1"] != phoneticScore {
+		t.Errorf("Expected the phonetic match to score %f but got %f", phoneticScore, scores["1"])
+	}
+
+	for _, id := range []string{"2", "3"} {
+
+		if scores[id] <= phoneticScore {
+			t.Errorf("Expected record %s to score more than the phonetic match but got %f", id, scores[id])
+		}
+	}
+
+	r, _, err := ftdb.QueryStringWithOptions(ctx, "chicago", opts)
+
+	if err != nil {
+		t.Fatalf("Failed to query, %v", err)
+	}
+
+	ids := make([]string, 0)
+
+	for _, s := range r.Results() {
+
+		ids = append(ids, s.Id())
+
+		result, ok := s.(*SearchResult)
+		phonetic := ok && result.Phonetic
+
+		if phonetic != (s.Id() == "1") {
+			t.Errorf("Expected record %s to be marked phonetic (%t) but got %t", s.Id(), s.Id() == "1", phonetic)
+		}
+	}
+
+	expected := []string{"2", "3", "1"}
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected the exact match first and the phonetic match last %v but got %v", expected, ids)
+	}
+
+	// Phonetic matches are only included when they are requested
+
+	opts.MatchMode = PlainTextMatch
+
+	ids = queryIds(t, ftdb, "chicago", opts)
+	expected = []string{"2", "3"}
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected only the records matched by their names %v but got %v", expected, ids)
+	}
+}
@@ -0,0 +1,360 @@
+package sqlite
+
+import (
+	"strings"
+	"unicode"
+)
+
+// hanPinyinSource lists the Hanyu Pinyin reading, without tones, of the (simplified and traditional) Han characters commonly found
+// in Chinese place names, one reading per line followed by its characters. Characters with more than one reading are listed under
+// the reading they have in place names, for example 重 as "chong" (重庆) and 厦 as "xia" (厦门).
+const hanPinyinSource string = `a 阿
+ai 艾爱愛
+an 安鞍岸
+ao 澳奥奧
+ba 巴八霸
+bai 白百柏拜
+ban 板班半
+bao 保包宝寶堡鲍鮑
+bei 北贝貝
+ben 本
+beng 蚌
+bi 碧毕畢璧
+bian 边邊汴
+bie 别別
+bin 滨濱宾賓彬
+bo 波博泊亳渤勃
+bu 布埠步
+cai 蔡
+cang 沧滄苍蒼
+cao 曹草
+chai 柴
+chang 长長昌常畅暢场場厂廠
+chao 朝潮巢
+chen 陈陳郴辰晨
+cheng 成城承程澄呈
+chi 赤池迟遲
+chong 重崇冲衝
+chu 楚滁处處初
+chuan 川船
+chun 春淳
+ci 慈磁
+cong 从從丛叢聪
+cun 村
+da 大达達
+dai 代岱戴黛
+dan 丹旦郸鄲儋
+dang 当當砀
+dao 岛島道稻
+de 德
+deng 登邓鄧
+di 迪堤地帝底狄
+dian 店甸滇典
+ding 定丁鼎顶頂
+dong 东東洞董栋
+dou 斗窦
+du 都杜独獨渡
+duan 段端
+dun 敦墩顿頓
+duo 多朵
+e 鄂峨额額俄
+en 恩
+er 二尔爾洱
+fan 番范繁樊凡
+fang 方房芳防坊舫
+fei 肥飞飛费
+fen 汾分芬
+feng 丰豐凤鳳峰风風封奉枫楓
+fo 佛
+fu 福府富阜抚撫涪扶芙浮伏复復
+gan 甘赣贛干
+gang 港岗崗钢
+gao 高皋
+ge 格葛歌阁閣
+gong 公宫宮贡貢工巩鞏
+gou 沟溝
+gu 古谷鼓姑固顾顧
+guan 关關观觀冠馆館灌
+guang 广廣光
+gui 贵貴桂归歸
+guo 国國郭果
+ha 哈
+hai 海
+han 汉漢韩韓邯寒涵汗翰
+hang 杭航
+hao 浩好郝濠
+he 河和合荷鹤鶴贺賀赫菏
+hei 黑
+heng 衡横橫恒恆
+hong 红紅洪宏鸿鴻
+hou 侯后厚
+hu 湖呼虎胡沪滬户葫
+hua 华華花化滑桦
+huai 淮怀懷
+huan 环環桓
+huang 黄黃皇潢
+hui 徽惠会會辉輝回
+hun 珲琿浑
+huo 霍火
+ji 吉济濟冀鸡雞基集蓟薊积積纪紀即姬
+jia 家嘉佳夹甲贾賈加
+jian 建剑劍涧澗尖简鉴坚堅
+jiang 江疆姜将將蒋蔣
+jiao 胶膠焦角交蛟郊礁
+jie 街界揭节節洁
+jin 津金晋晉锦錦进近今
+jing 京荆景井靖泾涇静晶经經
+jiu 九酒久
+ju 莒巨居菊聚
+jun 军軍郡峻
+ka 喀卡
+kai 开開凯凱
+kang 康
+ke 克科柯可
+kou 口
+ku 库庫
+kun 昆坤
+la 拉腊臘
+lai 莱萊来來赖
+lan 兰蘭蓝藍澜瀾岚嵐
+lang 廊朗琅浪郎
+lao 老崂嶗
+le 乐樂勒
+lei 雷耒
+leng 冷
+li 里丽麗黎利李礼禮立历歷漓醴理荔
+lian 连連莲蓮廉涟漣联聯
+liang 梁凉涼良两兩亮
+liao 辽遼聊廖蓼
+lin 林临臨霖麟琳
+ling 陵岭嶺灵靈凌玲铃零龄
+liu 柳六刘劉流浏瀏
+long 龙龍隆陇隴
+lou 楼樓娄婁
+lu 路鲁魯卢盧泸瀘陆陸鹿芦蘆潞禄庐廬露绿綠吕呂旅
+luan 滦灤栾
+lun 伦倫轮
+luo 洛罗羅骆螺落漯
+ma 马馬麻玛瑪
+man 满滿曼蔓
+mao 茂毛茅
+mei 梅美眉湄
+men 门門
+meng 蒙孟勐盟
+mi 米密弥彌汨泌
+mian 绵綿勉沔渑澠
+miao 苗庙廟妙
+min 闽閩民岷敏
+ming 明鸣鳴名
+mo 漠莫墨磨摩
+mu 牡木穆沐睦
+na 那纳納
+nai 乃奈
+nan 南楠
+nei 内內
+neng 能
+ni 尼泥倪
+nian 年
+ning 宁寧凝
+niu 牛
+nong 农農
+nu 怒努
+pan 盘盤潘攀磐
+peng 彭蓬鹏鵬澎
+pi 皮郫邳
+ping 平萍屏坪凭
+pu 浦普莆埔濮蒲圃
+qi 齐齊七奇淇岐祁旗启啟綦
+qian 千前黔乾钱錢潜潛迁遷
+qiang 羌强墙
+qiao 桥橋乔喬
+qin 秦钦欽沁琴勤芹
+qing 青清庆慶晴卿
+qiong 琼瓊邛穹
+qiu 丘邱秋
+qu 区區曲渠衢瞿屈
+quan 泉全权
+que 雀确
+rao 饶饒
+ren 仁任人
+ri 日
+rong 荣榮容蓉融榕戎
+ru 如汝乳儒
+rui 瑞睿芮
+run 润潤
+sa 萨薩
+san 三
+sang 桑
+sha 沙莎
+shaan 陕陝
+shan 山汕善杉珊鄯
+shang 上商尚
+shao 韶绍紹邵少
+she 社舍歙
+shen 深沈申神莘
+sheng 省胜勝圣聖盛升生
+shi 市石十狮獅施师師诗詩世实實史始时時士
+shou 寿壽首守
+shu 舒蜀树樹书書沭
+shuang 双雙
+shui 水
+shun 顺順
+shuo 朔
+si 四思泗寺丝絲司斯
+song 松嵩宋淞
+su 苏蘇宿肃肅素
+sui 绥綏随隨遂睢穗
+sun 孙孫
+suo 索
+ta 塔
+tai 台臺太泰
+tan 潭谭譚坛壇郯
+tang 唐塘汤湯堂棠
+tao 桃洮陶涛濤
+te 特
+teng 滕腾騰藤
+tian 天田
+tie 铁鐵
+ting 亭汀庭廷
+tong 通铜銅桐同潼童
+tou 头頭
+tu 图圖土吐涂途
+tuan 团團
+tun 屯
+tuo 托沱驼拓
+wa 瓦
+wan 湾灣万萬宛皖
+wang 王望汪旺
+wei 威潍濰渭卫衛魏伟偉维維围圍尉巍微尾
+wen 温溫文汶闻聞
+wo 沃
+wu 武无無吴吳乌烏五梧芜蕪婺舞
+xi 西溪锡錫喜息习習昔奚熙犀夕
+xia 下夏霞厦廈峡峽
+xian 县縣先仙咸贤賢显顯鲜鮮
+xiang 乡鄉香湘襄祥翔项向相
+xiao 小孝晓曉萧蕭肖
+xie 谢謝协協
+xin 新信忻鑫心辛欣
+xing 兴興星邢行杏
+xiong 雄熊
+xiu 秀修岫
+xu 许許徐须旭
+xuan 宣玄轩軒
+xue 雪薛学學
+xun 寻尋浔潯
+ya 雅亚亞崖
+yan 延烟煙盐鹽岩沿燕雁阎閻兖兗偃鄢堰
+yang 阳陽扬揚杨楊洋羊
+yao 姚尧堯遥耀瑶
+ye 叶葉野业業
+yi 伊宜义義益沂仪儀彝夷依颐頤易一邑
+yin 银銀阴陰殷印音尹
+ying 营營英鹰鷹颍潁瀛应應迎盈
+yong 永雍涌勇邕
+you 友攸酉右有油
+yu 玉余榆渝禹雨鱼魚于宇羽郁豫域峪虞禺
+yuan 元原源园園远遠渊淵沅苑袁
+yue 月越岳粤粵
+yun 云雲运運郓鄆芸
+zang 藏
+zao 枣棗造
+ze 泽澤则則
+zeng 曾增
+zha 扎札
+zhai 寨
+zhan 湛站詹
+zhang 张張章彰樟漳
+zhao 昭赵趙肇召招
+zhe 浙柘
+zhen 镇鎮真珍贞貞圳
+zheng 郑鄭正政
+zhi 芝治志支枝织織直智
+zhong 中钟鐘忠仲
+zhou 州周洲舟
+zhu 珠株竹朱诸諸驻駐
+zhuang 庄莊壮壯
+zhuo 涿卓
+zi 自紫资資淄梓
+zong 宗
+zou 邹鄒
+zui 嘴
+zun 遵
+zuo 左`
+
+// The Han characters for generic administrative terms, for example 市 (city), which are written as separate words when they end a
+// place name of three or more characters, for example "Beijing Shi" rather than "Beijingshi".
+const hanGenericSuffixes string = "市省县縣区區镇鎮乡鄉"
+
+// hanPinyin maps Han characters to their Hanyu Pinyin reading (see `hanPinyinSource`).
+var hanPinyin map[rune]string
+
+func init() {
+
+	hanPinyin = make(map[rune]string)
+
+	for _, line := range strings.Split(hanPinyinSource, "\n") {
+
+		parts := strings.Fields(line)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		for _, r := range parts[1] {
+			hanPinyin[r] = parts[0]
+		}
+	}
+}
+
+// transliterateHan returns the Hanyu Pinyin romanization, without tones, of 'text' which is expected to be written in (Chinese) Han
+// characters. The syllables of each run of Han characters are written as a single word, following the conventions for Chinese place
+// names, with any generic administrative suffix (see `hanGenericSuffixes`) written separately, for example "北京市" becomes "beijing shi".
+// Only the characters commonly found in place names can be transliterated (see `hanPinyinSource`) so names containing any other Han
+// characters are not.
+func transliterateHan(text string) (string, bool) {
+
+	var sb strings.Builder
+
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+
+		r := runes[i]
+
+		if !unicode.Is(unicode.Han, r) {
+
+			if unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r) {
+				return "", false
+			}
+
+			sb.WriteRune(r)
+			continue
+		}
+
+		start := i
+
+		for i+1 < len(runes) && unicode.Is(unicode.Han, runes[i+1]) {
+			i += 1
+		}
+
+		run := runes[start : i+1]
+
+		for j, han := range run {
+
+			latin, ok := hanPinyin[han]
+
+			if !ok {
+				return "", false
+			}
+
+			if j > 0 && j == len(run)-1 && len(run) >= 3 && strings.ContainsRune(hanGenericSuffixes, han) {
+				sb.WriteString(" ")
+			}
+
+			sb.WriteString(latin)
+		}
+	}
+
+	return sb.String(), true
+}
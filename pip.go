@@ -0,0 +1,325 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PointInPolygon returns the records whose geometry contains the point defined by 'lat' and 'lon' and which pass all of 'filters'.
+// Candidate records are found using the `rtree` table and then tested for exact containment using the geometry stored in the
+// `geojson` table so both tables must be present in the database. Results are sorted by the area of their bounding boxes, smallest
+// (most specific) first.
+func (ftdb *SQLiteFullTextDatabase) PointInPolygon(ctx context.Context, lat float64, lon float64, filters ...filter.Filter) (wof_spr.StandardPlacesResults, error) {
+
+	if lat < -90.0 || lat > 90.0 {
+		return nil, fmt.Errorf("Invalid latitude, %f", lat)
+	}
+
+	if lon < -180.0 || lon > 180.0 {
+		return nil, fmt.Errorf("Invalid longitude, %f", lon)
+	}
+
+	ev := &QueryEvent{
+		Database: ftdb.db.DSN(),
+		Term:     fmt.Sprintf("POINT(%f %f)", lon, lat),
+	}
+
+	t1 := time.Now()
+
+	ranked, err := ftdb.pointInPolygonWithEvent(ctx, orb.Point{lon, lat}, ev, filters...)
+
+	ev.Latency = time.Since(t1)
+	ev.Results = len(ranked)
+	ev.Error = err
+
+	for _, o := range ftdb.observers {
+		o.ObserveQuery(ctx, ev)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resultsFromRankedResults(ctx, ranked, ranked, nil)
+}
+
+func (ftdb *SQLiteFullTextDatabase) pointInPolygonWithEvent(ctx context.Context, pt orb.Point, ev *QueryEvent, filters ...filter.Filter) ([]*rankedResult, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ftdb.requireTables(ctx, conn, "point-in-polygon queries", ftdb.rtree_table, ftdb.geojson_table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	for _, f := range sql_filters {
+		ev.SQLFilters = append(ev.SQLFilters, describeFilter(f))
+	}
+
+	for _, f := range go_filters {
+		ev.GoFilters = append(ev.GoFilters, describeFilter(f))
+	}
+
+	q := fmt.Sprintf("SELECT DISTINCT wof_id FROM %s WHERE min_x <= ? AND max_x >= ? AND min_y <= ? AND max_y >= ? AND is_alt = 0", ftdb.rtree_table.Name())
+	ev.SQL = q
+
+	t_match := time.Now()
+
+	rows, err := conn.QueryContext(ctx, q, pt.X(), pt.X(), pt.Y(), pt.Y())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	candidates := make([]int64, 0)
+
+	for rows.Next() {
+
+		var id int64
+
+		err := rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, id)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ev.RowsMatched = len(candidates)
+	ev.addStage("match", t_match)
+
+	t_fetch := time.Now()
+
+	ranked := make([]*rankedResult, 0)
+
+	// The bounding box values in SPR records can not be relied on (see notes in go-whosonfirst-sqlite-spr
+	// about the order in which columns are scanned) so use the geometry instead.
+	areas := make(map[*rankedResult]float64)
+
+	for idx, id := range candidates {
+
+		geom, err := ftdb.retrieveGeometry(ctx, conn, id)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve geometry for %d, %w", id, err)
+		}
+
+		if !geometryContainsPoint(geom, pt) {
+			continue
+		}
+
+		spr_r, err := spr.RetrieveSPR(ctx, ftdb.db, ftdb.spr_table, id, "")
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve SPR for %d, %w", id, err)
+		}
+
+		if !matchesFilters(spr_r, go_filters...) {
+			continue
+		}
+
+		ok, err := ftdb.matchesSQLFilters(ctx, conn, id, sql_filters...)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to apply filters to %d, %w", id, err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		r := &rankedResult{
+			SPR:    spr_r,
+			Score:  1.0,
+			Index:  idx,
+			source: ftdb,
+		}
+
+		bbox := geom.Bound()
+		areas[r] = (bbox.Max.X() - bbox.Min.X()) * (bbox.Max.Y() - bbox.Min.Y())
+
+		ranked = append(ranked, r)
+	}
+
+	ev.SPRFetchTime = time.Since(t_fetch)
+	ev.addStage("contains", t_fetch)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+
+		area_a := areas[ranked[i]]
+		area_b := areas[ranked[j]]
+
+		if area_a != area_b {
+			return area_a < area_b
+		}
+
+		return ranked[i].SPR.Id() < ranked[j].SPR.Id()
+	})
+
+	return ranked, nil
+}
+
+// matchesSQLFilters returns a boolean value indicating whether the (default) record for 'id' in the spr table passes all of 'filters'.
+func (ftdb *SQLiteFullTextDatabase) matchesSQLFilters(ctx context.Context, conn *sql.DB, id int64, filters ...SQLFilter) (bool, error) {
+
+	where := []string{
+		"s.id = ?",
+		"s.alt_label = ''",
+	}
+
+	args := []interface{}{
+		strconv.FormatInt(id, 10),
+	}
+
+	for _, f := range filters {
+
+		clause, clause_args := f.WhereSQL("s")
+
+		if clause == "" {
+			continue
+		}
+
+		where = append(where, clause)
+		args = append(args, clause_args...)
+	}
+
+	if len(where) == 2 {
+		return true, nil
+	}
+
+	q := fmt.Sprintf("SELECT COUNT(s.id) FROM %s s WHERE %s", ftdb.spr_table.Name(), strings.Join(where, " AND "))
+
+	var count int64
+
+	err := conn.QueryRowContext(ctx, q, args...).Scan(&count)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// retrieveGeometry returns the (default) geometry for 'id' stored in the geojson table.
+func (ftdb *SQLiteFullTextDatabase) retrieveGeometry(ctx context.Context, conn *sql.DB, id int64) (orb.Geometry, error) {
+
+	q := fmt.Sprintf("SELECT body FROM %s WHERE id = ? AND is_alt = 0 LIMIT 1", ftdb.geojson_table.Name())
+
+	var body string
+
+	err := conn.QueryRowContext(ctx, q, id).Scan(&body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := geojson.UnmarshalFeature([]byte(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Geometry == nil {
+		return nil, errors.New("Feature has no geometry")
+	}
+
+	return f.Geometry, nil
+}
+
+// geometryContainsPoint returns a boolean value indicating whether 'pt' is contained by 'geom'. Only polygons and
+// multi-polygons can contain points. Points inside an interior ring (a hole) are not contained.
+func geometryContainsPoint(geom orb.Geometry, pt orb.Point) bool {
+
+	switch g := geom.(type) {
+	case orb.Polygon:
+		return polygonContainsPoint(g, pt)
+	case orb.MultiPolygon:
+
+		for _, poly := range g {
+
+			if polygonContainsPoint(poly, pt) {
+				return true
+			}
+		}
+
+		return false
+
+	default:
+		return false
+	}
+}
+
+func polygonContainsPoint(poly orb.Polygon, pt orb.Point) bool {
+
+	if len(poly) == 0 {
+		return false
+	}
+
+	if !poly.Bound().Contains(pt) {
+		return false
+	}
+
+	if !ringContainsPoint(poly[0], pt) {
+		return false
+	}
+
+	for _, hole := range poly[1:] {
+
+		if ringContainsPoint(hole, pt) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ringContainsPoint uses the even-odd (ray casting) rule to determine whether 'pt' is inside 'ring'.
+func ringContainsPoint(ring orb.Ring, pt orb.Point) bool {
+
+	inside := false
+	count := len(ring)
+
+	for i, j := 0, count-1; i < count; j, i = i, i+1 {
+
+		a := ring[i]
+		b := ring[j]
+
+		if (a.Y() > pt.Y()) != (b.Y() > pt.Y()) {
+
+			x := (b.X()-a.X())*(pt.Y()-a.Y())/(b.Y()-a.Y()) + a.X()
+
+			if pt.X() < x {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The default upper bounds, in seconds, of the latency histogram buckets recorded by `PrometheusObserver`.
+var DefaultPrometheusBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusObserver implements the `Observer` interface and aggregates query and index events in to metrics that
+// can be exported using the Prometheus text exposition format.
+type PrometheusObserver struct {
+	Observer
+	mu        *sync.Mutex
+	buckets   []float64
+	databases map[string]*prometheusMetrics
+}
+
+type prometheusHistogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+type prometheusMetrics struct {
+	queries          int64
+	query_errors     int64
+	cache_hits       int64
+	rows_matched     int64
+	spr_fetch_time   float64
+	query_latency    *prometheusHistogram
+	index_operations int64
+	index_errors     int64
+	index_latency    *prometheusHistogram
+}
+
+// NewPrometheusObserver returns a new `PrometheusObserver` instance using the `DefaultPrometheusBuckets` latency histogram buckets.
+func NewPrometheusObserver() *PrometheusObserver {
+	return NewPrometheusObserverWithBuckets(DefaultPrometheusBuckets)
+}
+
+// NewPrometheusObserverWithBuckets returns a new `PrometheusObserver` instance using 'buckets' (in seconds) for its latency histograms.
+func NewPrometheusObserverWithBuckets(buckets []float64) *PrometheusObserver {
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	o := &PrometheusObserver{
+		mu:        new(sync.Mutex),
+		buckets:   sorted,
+		databases: make(map[string]*prometheusMetrics),
+	}
+
+	return o
+}
+
+func (o *PrometheusObserver) ObserveQuery(ctx context.Context, ev *QueryEvent) {
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m := o.metrics(ev.Database)
+
+	m.queries += 1
+
+	if ev.Cached {
+		m.cache_hits += 1
+	}
+
+	m.rows_matched += int64(ev.RowsMatched)
+	m.spr_fetch_time += ev.SPRFetchTime.Seconds()
+
+	if ev.Error != nil {
+		m.query_errors += 1
+	}
+
+	o.observeLatency(m.query_latency, ev.Latency)
+}
+
+func (o *PrometheusObserver) ObserveIndex(ctx context.Context, ev *IndexEvent) {
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m := o.metrics(ev.Database)
+
+	m.index_operations += 1
+
+	if ev.Error != nil {
+		m.index_errors += 1
+	}
+
+	o.observeLatency(m.index_latency, ev.Latency)
+}
+
+// ServeHTTP writes the current metrics to 'rsp' using the Prometheus text exposition format.
+func (o *PrometheusObserver) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+
+	rsp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	err := o.WriteMetrics(rsp)
+
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// WriteMetrics writes the current metrics to 'wr' using the Prometheus text exposition format.
+func (o *PrometheusObserver) WriteMetrics(wr io.Writer) error {
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	names := make([]string, 0)
+
+	for name, _ := range o.databases {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(wr)
+
+	writeCounter := func(metric string, help string, value func(m *prometheusMetrics) string) {
+
+		fmt.Fprintf(buf, "# HELP %s %s\n", metric, help)
+		fmt.Fprintf(buf, "# TYPE %s counter\n", metric)
+
+		for _, name := range names {
+			fmt.Fprintf(buf, "%s{database=\"%s\"} %s\n", metric, escapeLabel(name), value(o.databases[name]))
+		}
+	}
+
+	writeHistogram := func(metric string, help string, value func(m *prometheusMetrics) *prometheusHistogram) {
+
+		fmt.Fprintf(buf, "# HELP %s %s\n", metric, help)
+		fmt.Fprintf(buf, "# TYPE %s histogram\n", metric)
+
+		for _, name := range names {
+
+			h := value(o.databases[name])
+			label := escapeLabel(name)
+
+			for idx, le := range o.buckets {
+				fmt.Fprintf(buf, "%s_bucket{database=\"%s\",le=\"%s\"} %d\n", metric, label, formatFloat(le), h.counts[idx])
+			}
+
+			fmt.Fprintf(buf, "%s_bucket{database=\"%s\",le=\"+Inf\"} %d\n", metric, label, h.count)
+			fmt.Fprintf(buf, "%s_sum{database=\"%s\"} %s\n", metric, label, formatFloat(h.sum))
+			fmt.Fprintf(buf, "%s_count{database=\"%s\"} %d\n", metric, label, h.count)
+		}
+	}
+
+	writeCounter("whosonfirst_search_sqlite_queries_total", "The total number of queries performed, including those whose results were read from the query cache.", func(m *prometheusMetrics) string {
+		return strconv.FormatInt(m.queries, 10)
+	})
+
+	writeCounter("whosonfirst_search_sqlite_query_errors_total", "The total number of queries that failed.", func(m *prometheusMetrics) string {
+		return strconv.FormatInt(m.query_errors, 10)
+	})
+
+	writeCounter("whosonfirst_search_sqlite_query_cache_hits_total", "The total number of queries whose results were read from the query cache.", func(m *prometheusMetrics) string {
+		return strconv.FormatInt(m.cache_hits, 10)
+	})
+
+	writeCounter("whosonfirst_search_sqlite_query_rows_matched_total", "The total number of rows matched by queries.", func(m *prometheusMetrics) string {
+		return strconv.FormatInt(m.rows_matched, 10)
+	})
+
+	writeCounter("whosonfirst_search_sqlite_spr_fetch_seconds_total", "The total time spent retrieving SPR records for queries.", func(m *prometheusMetrics) string {
+		return formatFloat(m.spr_fetch_time)
+	})
+
+	writeHistogram("whosonfirst_search_sqlite_query_duration_seconds", "The time spent performing queries.", func(m *prometheusMetrics) *prometheusHistogram {
+		return m.query_latency
+	})
+
+	writeCounter("whosonfirst_search_sqlite_index_operations_total", "The total number of features indexed.", func(m *prometheusMetrics) string {
+		return strconv.FormatInt(m.index_operations, 10)
+	})
+
+	writeCounter("whosonfirst_search_sqlite_index_errors_total", "The total number of features that failed to be indexed.", func(m *prometheusMetrics) string {
+		return strconv.FormatInt(m.index_errors, 10)
+	})
+
+	writeHistogram("whosonfirst_search_sqlite_index_duration_seconds", "The time spent indexing features.", func(m *prometheusMetrics) *prometheusHistogram {
+		return m.index_latency
+	})
+
+	return buf.Flush()
+}
+
+// metrics returns the metrics for 'database', creating them if necessary. It assumes the caller holds o.mu.
+func (o *PrometheusObserver) metrics(database string) *prometheusMetrics {
+
+	m, ok := o.databases[database]
+
+	if !ok {
+
+		m = &prometheusMetrics{
+			query_latency: &prometheusHistogram{
+				counts: make([]int64, len(o.buckets)),
+			},
+			index_latency: &prometheusHistogram{
+				counts: make([]int64, len(o.buckets)),
+			},
+		}
+
+		o.databases[database] = m
+	}
+
+	return m
+}
+
+// observeLatency records 'd' in 'h'. Bucket counts are cumulative as required by the Prometheus exposition format.
+func (o *PrometheusObserver) observeLatency(h *prometheusHistogram, d time.Duration) {
+
+	secs := d.Seconds()
+
+	for idx, le := range o.buckets {
+
+		if secs <= le {
+			h.counts[idx] += 1
+		}
+	}
+
+	h.sum += secs
+	h.count += 1
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeLabel(str string) string {
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return r.Replace(str)
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusCacheHits(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "cache_size=10", testFeature(1, "Paris", 48.86, 2.35, nil))
+
+	metrics := NewPrometheusObserver()
+	ftdb.AddObserver(metrics)
+
+	// The second query is read from the cache but must still be counted
+
+	for i := 0; i < 2; i++ {
+		queryIds(t, ftdb, "paris", nil)
+	}
+
+	var buf bytes.Buffer
+
+	err := metrics.WriteMetrics(&buf)
+
+	if err != nil {
+		t.Fatalf("Failed to write metrics, %v", err)
+	}
+
+	expected := []string{
+		`whosonfirst_search_sqlite_queries_total{database="%s"} 2`,
+		`whosonfirst_search_sqlite_query_cache_hits_total{database="%s"} 1`,
+		`whosonfirst_search_sqlite_query_duration_seconds_count{database="%s"} 2`,
+	}
+
+	for _, line := range expected {
+
+		line = fmt.Sprintf(line, escapeLabel(ftdb.db.DSN()))
+
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected metrics to contain '%s'", line)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/tidwall/gjson"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The name of the properties table created by go-whosonfirst-sqlite-features. SQL expressions for filters and sort orders
+// are derived without access to a database so they assume this name.
+const propertiesTableName string = "properties"
+
+var re_property_path = regexp.MustCompile(`^[A-Za-z0-9_:\-]+(\.[A-Za-z0-9_:\-]+)*$`)
+
+var re_property_expr = regexp.MustCompile(`^([A-Za-z0-9_:\-\.]+)\s*(!=|>=|<=|=|<|>)\s*(.*)$`)
+
+// The comparison operators supported by `PropertyFilter`.
+var propertyOperators = []string{"=", "!=", "<", "<=", ">", ">="}
+
+// PropertyFilter restricts results to records whose value for a property, read from the properties table, compares to
+// a given value. Records that do not have the property never match. Properties are not part of a record's SPR so the
+// filter can only be applied in SQL.
+type PropertyFilter struct {
+	nullFilter
+	// The path of the property, for example "wof:population" or "wof:hierarchy.0.country_id".
+	Path string
+	// The comparison operator, one of "=", "!=", "<", "<=", ">" or ">=".
+	Operator string
+	// The value the property is compared to. Numeric (and boolean) values are compared as numbers, everything else as a string.
+	Value interface{}
+}
+
+// ValidatePropertyPath ensures that 'path' is a dot-separated list of property names, for example "wof:population" or
+// "wof:hierarchy.0.country_id". Numeric names are treated as array indices.
+func ValidatePropertyPath(path string) error {
+
+	if !re_property_path.MatchString(path) {
+		return fmt.Errorf("Invalid property path '%s'", path)
+	}
+
+	return nil
+}
+
+// ParsePropertyPaths returns the list of property paths defined by 'str' which is a comma-separated list of paths.
+func ParsePropertyPaths(str string) ([]string, error) {
+
+	paths := make([]string, 0)
+
+	for _, p := range strings.Split(str, ",") {
+
+		p = strings.TrimSpace(p)
+
+		if p == "" {
+			continue
+		}
+
+		err := ValidatePropertyPath(p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+// NewPropertyFilter returns a new `PropertyFilter` for 'expr' which is a property path, a comparison operator and a value.
+// For example "wof:population>1000000" or "iso:country=CA".
+func NewPropertyFilter(expr string) (*PropertyFilter, error) {
+
+	m := re_property_expr.FindStringSubmatch(strings.TrimSpace(expr))
+
+	if m == nil {
+		return nil, fmt.Errorf("Invalid property filter '%s'", expr)
+	}
+
+	path := m[1]
+	op := m[2]
+	str_value := strings.TrimSpace(m[3])
+
+	err := ValidatePropertyPath(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if str_value == "" {
+		return nil, fmt.Errorf("Invalid property filter '%s', missing value", expr)
+	}
+
+	f := &PropertyFilter{
+		Path:     path,
+		Operator: op,
+		Value:    parsePropertyValue(str_value),
+	}
+
+	return f, nil
+}
+
+func (f *PropertyFilter) WhereSQL(alias string) (string, []interface{}) {
+
+	// The operator is interpolated so make sure it's one we know about
+	if !stringInList(f.Operator, propertyOperators) {
+		return "0", nil
+	}
+
+	clause := fmt.Sprintf("%s %s ?", propertyValueSQL(alias), f.Operator)
+	args := []interface{}{propertyJSONPath(f.Path), f.Value}
+
+	return clause, args
+}
+
+// MatchesSPR always returns true since properties are not part of a record's SPR. The filter is applied in SQL.
+func (f *PropertyFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+	return true
+}
+
+func (f *PropertyFilter) String() string {
+	return fmt.Sprintf("property=%s%s%v", f.Path, f.Operator, f.Value)
+}
+
+// parsePropertyValue returns 'str' as a number if it can be parsed as one, 1 or 0 for "true" and "false" (which is how
+// SQLite's JSON functions represent booleans) and as a string otherwise.
+func parsePropertyValue(str string) interface{} {
+
+	switch str {
+	case "true":
+		return int64(1)
+	case "false":
+		return int64(0)
+	}
+
+	i, err := strconv.ParseInt(str, 10, 64)
+
+	if err == nil {
+		return i
+	}
+
+	fl, err := strconv.ParseFloat(str, 64)
+
+	if err == nil {
+		return fl
+	}
+
+	return str
+}
+
+// propertyJSONPath returns the SQLite JSON path for 'path', for example `$."wof:hierarchy"[0]."country_id"`.
+func propertyJSONPath(path string) string {
+
+	var sb strings.Builder
+	sb.WriteString("$")
+
+	for _, name := range strings.Split(path, ".") {
+
+		_, err := strconv.Atoi(name)
+
+		if err == nil {
+			sb.WriteString(fmt.Sprintf("[%s]", name))
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf(".%q", name))
+	}
+
+	return sb.String()
+}
+
+// propertyValueSQL returns a SQL expression for the value of a property of the (default) record whose ID is in the spr table
+// column prefixed with 'alias'. The expression has a single argument which is the JSON path of the property (see `propertyJSONPath`).
+func propertyValueSQL(alias string) string {
+	return fmt.Sprintf("(SELECT json_extract(p.body, ?) FROM %s p WHERE p.id = CAST(%s.id AS INTEGER) AND p.alt_label = '')", propertiesTableName, alias)
+}
+
+// propertyValues returns the values of 'paths' in 'body', which is a record's properties encoded as JSON, keyed by path.
+// Properties the record does not have are nil.
+func propertyValues(body string, paths []string) map[string]interface{} {
+
+	values := make(map[string]interface{})
+
+	for _, p := range paths {
+
+		rsp := gjson.Get(body, p)
+
+		if !rsp.Exists() {
+			values[p] = nil
+			continue
+		}
+
+		values[p] = rsp.Value()
+	}
+
+	return values
+}
+
+// sortPropertyPaths returns the property paths that results are sorted by in 'sorts'.
+func sortPropertyPaths(sorts []*SortOrder) []string {
+
+	paths := make([]string, 0)
+
+	for _, s := range sorts {
+
+		if s.Field == SortByProperty && !stringInList(s.Property, paths) {
+			paths = append(paths, s.Property)
+		}
+	}
+
+	return paths
+}
+
+// loadRankedProperties reads the values of 'paths' for each of 'ranked' from the properties table of the database it was
+// retrieved from and adds them to its properties.
+func loadRankedProperties(ctx context.Context, ranked []*rankedResult, paths []string) error {
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	ids := make(map[*SQLiteFullTextDatabase][]interface{})
+	order := make([]*SQLiteFullTextDatabase, 0)
+
+	for _, r := range ranked {
+
+		_, exists := ids[r.source]
+
+		if !exists {
+			order = append(order, r.source)
+		}
+
+		ids[r.source] = append(ids[r.source], r.SPR.Id())
+	}
+
+	bodies := make(map[*SQLiteFullTextDatabase]map[string]string)
+
+	for _, ftdb := range order {
+
+		db_bodies, err := ftdb.retrieveProperties(ctx, ids[ftdb])
+
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve properties for %s, %w", ftdb.db.DSN(), err)
+		}
+
+		bodies[ftdb] = db_bodies
+	}
+
+	for _, r := range ranked {
+
+		values := propertyValues(bodies[r.source][r.SPR.Id()], paths)
+
+		if r.properties == nil {
+			r.properties = make(map[string]interface{})
+		}
+
+		for k, v := range values {
+			r.properties[k] = v
+		}
+	}
+
+	return nil
+}
+
+// retrieveProperties returns the (default) properties stored in the properties table for 'ids', keyed by ID.
+func (ftdb *SQLiteFullTextDatabase) retrieveProperties(ctx context.Context, ids []interface{}) (map[string]string, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ftdb.requireTables(ctx, conn, "extra properties", ftdb.properties_table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := make(map[string]string)
+
+	for start := 0; start < len(ids); start += maxQueryParameters {
+
+		end := start + maxQueryParameters
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		err := ftdb.retrievePropertiesBatch(ctx, conn, ids[start:end], bodies)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bodies, nil
+}
+
+// retrievePropertiesBatch adds the (default) properties stored in the properties table for 'ids' to 'bodies'.
+func (ftdb *SQLiteFullTextDatabase) retrievePropertiesBatch(ctx context.Context, conn *sql.DB, ids []interface{}, bodies map[string]string) error {
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	q := fmt.Sprintf("SELECT id, body FROM %s WHERE alt_label = '' AND id IN (%s)", ftdb.properties_table.Name(), placeholders)
+
+	rows, err := conn.QueryContext(ctx, q, ids...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var id int64
+		var body string
+
+		err := rows.Scan(&id, &body)
+
+		if err != nil {
+			return err
+		}
+
+		bodies[strconv.FormatInt(id, 10)] = body
+	}
+
+	return rows.Err()
+}
+
+// compareProperties compares the property values 'a' and 'b' in the same order as SQLite: missing values, then numbers
+// (including booleans), then strings (see `compareNames`), then anything else compared as JSON. It returns -1, 0 or 1.
+func compareProperties(a interface{}, b interface{}) int {
+
+	class := func(v interface{}) int {
+
+		switch v.(type) {
+		case nil:
+			return 0
+		case float64, bool:
+			return 1
+		case string:
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	number := func(v interface{}) float64 {
+
+		switch v.(type) {
+		case bool:
+
+			if v.(bool) {
+				return 1.0
+			}
+
+			return 0.0
+
+		default:
+			return v.(float64)
+		}
+	}
+
+	c_a := class(a)
+	c_b := class(b)
+
+	if c_a != c_b {
+		return compareInt64(int64(c_a), int64(c_b))
+	}
+
+	switch c_a {
+	case 0:
+		return 0
+	case 1:
+		return compareFloat64(number(a), number(b))
+	case 2:
+		return compareNames(a.(string), b.(string))
+	default:
+
+		enc_a, _ := json.Marshal(a)
+		enc_b, _ := json.Marshal(b)
+
+		return strings.Compare(string(enc_a), string(enc_b))
+	}
+}
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+	"strings"
+)
+
+// The maximum number of ways a search term is split in to a place and its qualifiers. Terms are only split between
+// words so this limits the number of words considered.
+const maxQualifiedSplits int = 8
+
+// qualifiedSplit is a search term split in to the name of a place and the names of one or more of its ancestors, for
+// example "paris" and "texas".
+type qualifiedSplit struct {
+	// The name of the place.
+	Place string
+	// The names of the place's ancestors, from most to least specific.
+	Qualifiers []string
+}
+
+// qualifiedSplits returns the ways that 'term' can be split in to a place and its qualifiers. If 'term' contains commas
+// it is only split at the commas, for example "Paris, Texas, USA". Otherwise it is split between each of its words with the
+// words after the split being a single qualifier, for example "london ontario" or "new york city" (as "new" and "york city"
+// or "new york" and "city").
+func qualifiedSplits(term string) []*qualifiedSplit {
+
+	splits := make([]*qualifiedSplit, 0)
+
+	if strings.Contains(term, ",") {
+
+		parts := make([]string, 0)
+
+		for _, p := range strings.Split(term, ",") {
+
+			p = strings.TrimSpace(p)
+
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+
+		if len(parts) > 1 {
+
+			splits = append(splits, &qualifiedSplit{
+				Place:      parts[0],
+				Qualifiers: parts[1:],
+			})
+		}
+
+		return splits
+	}
+
+	words := strings.Fields(term)
+
+	for i := 1; i < len(words) && len(splits) < maxQualifiedSplits; i++ {
+
+		splits = append(splits, &qualifiedSplit{
+			Place:      strings.Join(words[:i], " "),
+			Qualifiers: []string{strings.Join(words[i:], " ")},
+		})
+	}
+
+	return splits
+}
+
+// qualifiedMatches returns the records matching the place part of each of the ways 'term' can be split (see `qualifiedSplits`)
+// whose ancestors match each of the qualifiers of that split. Qualifiers are matched against the names of localities and
+// less specific placetypes (see `StructuredQuery`). Records in 'exclude', keyed by ID, are ignored. Each record's relevance
+// is derived from the place part of the term. Results are returned in the order they are matched.
+func (ftdb *SQLiteFullTextDatabase) qualifiedMatches(ctx context.Context, conn *sql.DB, term string, opts *QueryOptions, exclude map[string]bool, filters ...filter.Filter) ([]*rankedResult, error) {
+
+	splits := qualifiedSplits(term)
+
+	if len(splits) == 0 {
+		return nil, nil
+	}
+
+	err := ftdb.requireTables(ctx, conn, "hierarchical queries", ftdb.ancestors_table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	expanders := ftdb.tokenExpanders(opts)
+
+	qualifier_placetypes := make([]string, 0)
+
+	for _, f := range structuredPlacetypes[1:] {
+		qualifier_placetypes = append(qualifier_placetypes, f.Placetypes...)
+	}
+
+	ranked := make([]*rankedResult, 0)
+	seen := make(map[string]bool)
+
+	for _, split := range splits {
+
+		// The IDs matching each qualifier, keyed by ID
+		matching := make([]map[int64]bool, 0)
+
+		for _, qualifier := range split.Qualifiers {
+
+			ids, err := ftdb.qualifiedCandidates(ctx, conn, qualifier, qualifier_placetypes, expanders...)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(ids) == 0 {
+				break
+			}
+
+			qualifier_ids := make(map[int64]bool)
+
+			for _, id := range ids {
+				qualifier_ids[id] = true
+			}
+
+			matching = append(matching, qualifier_ids)
+		}
+
+		// A qualifier didn't match anything so there is no point looking for the place
+		if len(matching) < len(split.Qualifiers) {
+			continue
+		}
+
+		place_ids, err := ftdb.qualifiedCandidates(ctx, conn, split.Place, nil, expanders...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ancestors, err := ftdb.retrieveAncestors(ctx, conn, place_ids)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve ancestors, %w", err)
+		}
+
+		for _, id := range place_ids {
+
+			str_id := fmt.Sprintf("%d", id)
+
+			if exclude[str_id] || seen[str_id] {
+				continue
+			}
+
+			if !structuredCoherent(id, ancestors[id], matching) {
+				continue
+			}
+
+			spr_r, err := spr.RetrieveSPR(ctx, ftdb.db, ftdb.spr_table, id, "")
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to retrieve SPR for %d, %w", id, err)
+			}
+
+			if !matchesFilters(spr_r, go_filters...) {
+				continue
+			}
+
+			ok, err := ftdb.matchesSQLFilters(ctx, conn, id, sql_filters...)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to apply filters to %d, %w", id, err)
+			}
+
+			if !ok {
+				continue
+			}
+
+			seen[str_id] = true
+
+			ranked = append(ranked, &rankedResult{
+				SPR:    spr_r,
+				Score:  relevance(split.Place, spr_r),
+				source: ftdb,
+			})
+		}
+	}
+
+	return ranked, nil
+}
+
+// qualifiedCandidates returns the IDs of the records, with any of 'placetypes' if not empty, whose names match 'term'. Terms that
+// do not contain anything that can be matched return no IDs rather than an error.
+func (ftdb *SQLiteFullTextDatabase) qualifiedCandidates(ctx context.Context, conn *sql.DB, term string, placetypes []string, expanders ...tokenExpander) ([]int64, error) {
+
+	match, err := matchExpression(term, PlainTextMatch, expanders...)
+
+	if err != nil {
+
+		if err == ErrEmptyQuery {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	q, args := ftdb.structuredMatchSQL(match, placetypes)
+
+	ids, err := ftdb.structuredMatches(ctx, conn, q, args...)
+
+	if err != nil {
+		return nil, wrapMatchError(term, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQualifiedSplits(t *testing.T) {
+
+	tests := []struct {
+		Term     string
+		Expected []string
+	}{
+		{Term: "paris texas", Expected: []string{"paris|texas"}},
+		{Term: "new york city", Expected: []string{"new|york city", "new york|city"}},
+		// Terms with commas are only split at the commas
+		{Term: "London, Ontario", Expected: []string{"London|Ontario"}},
+		{Term: "Paris, Texas, USA", Expected: []string{"Paris|Texas|USA"}},
+		{Term: "new york, usa", Expected: []string{"new york|usa"}},
+		// Terms that can't be split
+		{Term: "paris", Expected: []string{}},
+		{Term: "paris, ", Expected: []string{}},
+	}
+
+	for _, test := range tests {
+
+		splits := make([]string, 0)
+
+		for _, s := range qualifiedSplits(test.Term) {
+			parts := append([]string{s.Place}, s.Qualifiers...)
+			splits = append(splits, strings.Join(parts, "|"))
+		}
+
+		if !equalIds(splits, test.Expected) {
+			t.Errorf("Expected '%s' to be split as %v but got %v", test.Term, test.Expected, splits)
+		}
+	}
+}
+
+func TestHierarchicalQueries(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(1, "France", 46.60, 2.40, map[string]interface{}{
+			"wof:placetype": "country",
+			"wof:country":   "FR",
+			"wof:hierarchy": []map[string]int64{
+				{"country_id": 1},
+			},
+		}),
+		testFeature(10, "Texas", 31.25, -99.25, map[string]interface{}{
+			"wof:placetype": "region",
+			"wof:country":   "US",
+			"wof:hierarchy": []map[string]int64{
+				{"region_id": 10},
+			},
+		}),
+		testFeature(100, "Paris", 48.86, 2.35, map[string]interface{}{
+			"wof:country": "FR",
+			"wof:hierarchy": []map[string]int64{
+				{"locality_id": 100, "country_id": 1},
+			},
+		}),
+		testFeature(200, "Paris", 33.66, -95.56, map[string]interface{}{
+			"wof:country": "US",
+			"wof:hierarchy": []map[string]int64{
+				{"locality_id": 200, "region_id": 10},
+			},
+		}),
+		testFeature(300, "Paris Hill", 44.25, -70.50, nil),
+	}
+
+	ftdb := newTestDatabase(t, "", features...)
+	indexAncestors(t, ftdb, features...)
+
+	opts, err := DefaultQueryOptions()
+
+	if err != nil {
+		t.Fatalf("Failed to create query options, %v", err)
+	}
+
+	opts.Hierarchical = true
+
+	tests := []struct {
+		Term     string
+		Expected []string
+	}{
+		// Paris, Texas is found through its ancestors
+		{Term: "paris texas", Expected: []string{"200"}},
+		{Term: "Paris, Texas", Expected: []string{"200"}},
+		{Term: "paris france", Expected: []string{"100"}},
+		// Terms that aren't qualified still return every match
+		{Term: "paris", Expected: []string{"100", "200", "300"}},
+		// Terms that match without being split return the same results as they would otherwise
+		{Term: "paris hill", Expected: []string{"300"}},
+		// Splits whose qualifiers don't match anything don't add any results
+		{Term: "paris ohio", Expected: []string{}},
+	}
+
+	for _, test := range tests {
+
+		ids := sortedIds(queryIds(t, ftdb, test.Term, opts))
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected '%s' to return %v but got %v", test.Term, test.Expected, ids)
+		}
+	}
+
+	// Qualified terms aren't split unless requested
+
+	ids := queryIds(t, ftdb, "paris texas", nil)
+
+	if len(ids) != 0 {
+		t.Errorf("Expected 'paris texas' not to match anything without hierarchical queries but got %v", ids)
+	}
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"sort"
+	"strings"
+)
+
+// rankedResult is a StandardPlacesResult and its relevance for a given query.
+type rankedResult struct {
+	SPR wof_spr.StandardPlacesResult
+	// The relevance of SPR for a given query, in the range 0.0 - 1.0.
+	Score float64
+	// The position of SPR in the (unranked) results returned by the database.
+	Index int
+	// The database SPR was retrieved from.
+	source *SQLiteFullTextDatabase
+	// The values of any properties, read from the properties table, keyed by path.
+	properties map[string]interface{}
+	// Whether SPR was only matched by the phonetic codes of its names.
+	phonetic bool
+}
+
+// relevance returns a score, in the range 0.0 - 1.0, indicating how closely 's' matches 'term'. Exact matches
+// on a record's ID or default name score highest, followed by prefix and substring matches on the default name.
+// Anything else was matched by one of the record's other names.
+func relevance(term string, s wof_spr.StandardPlacesResult) float64 {
+
+	term = strings.ToLower(strings.TrimSpace(term))
+	name := strings.ToLower(s.Name())
+
+	switch {
+	case term == s.Id(), term == name:
+		return 1.0
+	case strings.HasPrefix(name, term):
+		return 0.75
+	case strings.Contains(name, term):
+		return 0.5
+	default:
+		return 0.25
+	}
+}
+
+// sortRankedResults sorts 'results' according to 'sorts' or, if empty, in descending order of relevance. Ties are broken using
+// the order in which results were returned by the database when sorting by relevance and by ID otherwise so that the final order
+// (and pagination) is deterministic.
+func sortRankedResults(results []*rankedResult, sorts ...*SortOrder) {
+
+	if len(sorts) == 0 {
+		sorts = []*SortOrder{
+			&SortOrder{Field: SortByRelevance},
+		}
+	}
+
+	by_relevance := !sortInSQL(sorts)
+
+	sort.SliceStable(results, func(i, j int) bool {
+
+		a := results[i]
+		b := results[j]
+
+		for _, s := range sorts {
+
+			c := compareRankedResults(a, b, s)
+
+			if c == 0 {
+				continue
+			}
+
+			if s.Descending {
+				c = -c
+			}
+
+			return c < 0
+		}
+
+		if by_relevance && a.Index != b.Index {
+			return a.Index < b.Index
+		}
+
+		c := compareInt64(parseSortId(a.SPR.Id()), parseSortId(b.SPR.Id()))
+
+		if c != 0 {
+			return c < 0
+		}
+
+		return a.SPR.Id() < b.SPR.Id()
+	})
+}
@@ -0,0 +1,284 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+)
+
+// SearchResult is a `spr.SQLiteStandardPlacesResult` with additional properties derived when a query is performed.
+type SearchResult struct {
+	*spr.SQLiteStandardPlacesResult
+	// A human-readable label derived from the names of the record's ancestors, for example "Montreal, Quebec, Canada".
+	Label string `json:"wof:label,omitempty"`
+	// The values of any extra properties, read from the properties table, keyed by path. They are encoded alongside
+	// the other properties of the result.
+	Extras map[string]interface{} `json:"-"`
+	// Whether the record was only matched by the phonetic codes of its names (see `PhoneticMatch`).
+	Phonetic bool `json:"search:phonetic,omitempty"`
+}
+
+// MarshalJSON encodes the result with any extra properties added to its other properties. Extra properties never replace
+// the properties of the SPR itself.
+func (r *SearchResult) MarshalJSON() ([]byte, error) {
+
+	type searchResult SearchResult
+
+	enc, err := json.Marshal((*searchResult)(r))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Extras) == 0 {
+		return enc, nil
+	}
+
+	var props map[string]interface{}
+
+	err = json.Unmarshal(enc, &props)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range r.Extras {
+
+		_, exists := props[k]
+
+		if !exists {
+			props[k] = v
+		}
+	}
+
+	return json.Marshal(props)
+}
+
+// SearchResults implements the `wof_spr.StandardPlacesResults` interface for a page of results along with optional
+// facet counts for all the results matching a query.
+type SearchResults struct {
+	wof_spr.StandardPlacesResults `json:",omitempty"`
+	Places                        []wof_spr.StandardPlacesResult `json:"places"`
+	Facets                        Facets                         `json:"facets,omitempty"`
+	// The ranked results corresponding to Places, used to find the database each result was retrieved from.
+	ranked []*rankedResult
+}
+
+func (r *SearchResults) Results() []wof_spr.StandardPlacesResult {
+	return r.Places
+}
+
+// resultsFromRankedResults returns a `SearchResults` instance for 'page' which is a subset of 'ranked'. If 'opts' requests
+// properties that are derived at query time, for example labels or extra properties, or if any result in 'page' was matched phonetically
+// then each of those results is returned as a `SearchResult`.
+// If 'opts' requests facets they are counted across all of 'ranked'.
+func resultsFromRankedResults(ctx context.Context, ranked []*rankedResult, page []*rankedResult, opts *QueryOptions) (*SearchResults, error) {
+
+	places := make([]wof_spr.StandardPlacesResult, len(page))
+
+	for idx, r := range page {
+		places[idx] = r.SPR
+	}
+
+	r := &SearchResults{
+		Places: places,
+		ranked: page,
+	}
+
+	err := markPhoneticRankedResults(page, places)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		return r, nil
+	}
+
+	if opts.Labels != nil {
+
+		err := labelRankedResults(ctx, page, places, opts.Labels)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.Extras) > 0 {
+
+		err := addExtrasToRankedResults(ctx, page, places, opts.Extras)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.Facets) > 0 {
+
+		facets, err := facetRankedResults(ctx, ranked, opts.Facets)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.Facets = facets
+	}
+
+	return r, nil
+}
+
+// labelRankedResults replaces each element in 'places', if it isn't already, with a `SearchResult` whose label is derived from the database
+// the corresponding element in 'ranked' was retrieved from.
+func labelRankedResults(ctx context.Context, ranked []*rankedResult, places []wof_spr.StandardPlacesResult, opts *LabelOptions) error {
+
+	err := validateLabelOptions(opts)
+
+	if err != nil {
+		return err
+	}
+
+	// Whether each database has a properties table, from which short names are read
+	has_properties := make(map[*SQLiteFullTextDatabase]bool)
+
+	for idx, r := range ranked {
+
+		ftdb := r.source
+
+		conn, err := ftdb.db.Conn()
+
+		if err != nil {
+			return err
+		}
+
+		_, checked := has_properties[ftdb]
+
+		if !checked {
+
+			err := ftdb.requireTables(ctx, conn, "labels", ftdb.ancestors_table, ftdb.spr_table)
+
+			if err != nil {
+				return err
+			}
+
+			has_props := false
+
+			if opts.ShortNames {
+
+				has_props, err = ftdb.hasTable(ctx, conn, ftdb.properties_table)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			has_properties[ftdb] = has_props
+		}
+
+		sqlite_spr, ok := r.SPR.(*spr.SQLiteStandardPlacesResult)
+
+		if !ok {
+			return fmt.Errorf("Unsupported SPR type %T", r.SPR)
+		}
+
+		label, err := ftdb.label(ctx, conn, sqlite_spr.Id(), sqlite_spr.Name(), opts, has_properties[ftdb])
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive label for %s, %w", sqlite_spr.Id(), err)
+		}
+
+		result, err := searchResultAt(places, idx, r)
+
+		if err != nil {
+			return err
+		}
+
+		result.Label = label
+	}
+
+	return nil
+}
+
+// addExtrasToRankedResults replaces each element in 'places', if it isn't already, with a `SearchResult` whose extra properties
+// are the values of 'paths' read from the database the corresponding element in 'ranked' was retrieved from.
+func addExtrasToRankedResults(ctx context.Context, ranked []*rankedResult, places []wof_spr.StandardPlacesResult, paths []string) error {
+
+	for _, p := range paths {
+
+		err := ValidatePropertyPath(p)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := loadRankedProperties(ctx, ranked, paths)
+
+	if err != nil {
+		return err
+	}
+
+	for idx, r := range ranked {
+
+		result, err := searchResultAt(places, idx, r)
+
+		if err != nil {
+			return err
+		}
+
+		result.Extras = make(map[string]interface{})
+
+		for _, p := range paths {
+			result.Extras[p] = r.properties[p]
+		}
+	}
+
+	return nil
+}
+
+// markPhoneticRankedResults replaces each element in 'places' whose corresponding element in 'ranked' was only matched phonetically
+// with a `SearchResult` that is marked as such.
+func markPhoneticRankedResults(ranked []*rankedResult, places []wof_spr.StandardPlacesResult) error {
+
+	for idx, r := range ranked {
+
+		if !r.phonetic {
+			continue
+		}
+
+		result, err := searchResultAt(places, idx, r)
+
+		if err != nil {
+			return err
+		}
+
+		result.Phonetic = true
+	}
+
+	return nil
+}
+
+// searchResultAt returns the element at 'idx' in 'places' as a `SearchResult`, replacing it with a new `SearchResult` for 'r' if it
+// isn't one already.
+func searchResultAt(places []wof_spr.StandardPlacesResult, idx int, r *rankedResult) (*SearchResult, error) {
+
+	result, ok := places[idx].(*SearchResult)
+
+	if ok {
+		return result, nil
+	}
+
+	sqlite_spr, ok := r.SPR.(*spr.SQLiteStandardPlacesResult)
+
+	if !ok {
+		return nil, fmt.Errorf("Unsupported SPR type %T", r.SPR)
+	}
+
+	result = &SearchResult{
+		SQLiteStandardPlacesResult: sqlite_spr,
+	}
+
+	places[idx] = result
+	return result, nil
+}
@@ -0,0 +1,226 @@
+package sqlite
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cyrillicLatin maps lower-case Cyrillic letters (Russian, Ukrainian, Belarusian, Serbian, Macedonian and Bulgarian) to their
+// Latin transliteration, following BGN/PCGN conventions where they differ.
+var cyrillicLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+// greekLatin maps lower-case Greek letters, including those with accents, to their Latin transliteration.
+var greekLatin = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o", 'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// greekDigraphs maps pairs of lower-case Greek letters that are transliterated together.
+var greekDigraphs = map[string]string{
+	"ου": "ou", "ού": "ou", "αι": "ai", "αί": "ai", "ει": "ei", "εί": "ei", "οι": "oi", "οί": "oi",
+	"αυ": "av", "αύ": "av", "ευ": "ev", "εύ": "ev", "γγ": "ng", "γκ": "gk", "μπ": "mp", "ντ": "nt",
+}
+
+// The Revised Romanization of the initial consonants, vowels and final consonants of a Hangul syllable.
+var hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+
+var hangulVowels = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+
+var hangulFinals = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+
+// kanaLatin maps Hiragana syllables (Katakana is mapped to Hiragana first) to their Hepburn romanization. Combinations with
+// small "ya", "yu" and "yo" are listed as pairs.
+var kanaLatin = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n", "ゔ": "vu",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o", "ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa",
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo", "ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "じゃ": "ja", "じゅ": "ju", "じょ": "jo",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo", "びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo", "みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+}
+
+// transliterateCyrillic returns the Latin transliteration of 'text' which is expected to be written in the Cyrillic script.
+func transliterateCyrillic(text string) (string, bool) {
+
+	var sb strings.Builder
+
+	for _, r := range strings.ToLower(text) {
+
+		latin, ok := cyrillicLatin[r]
+
+		switch {
+		case ok:
+			sb.WriteString(latin)
+		case unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r):
+			return "", false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String(), true
+}
+
+// transliterateGreek returns the Latin transliteration of 'text' which is expected to be written in the Greek script.
+func transliterateGreek(text string) (string, bool) {
+
+	var sb strings.Builder
+
+	runes := []rune(strings.ToLower(text))
+
+	for i := 0; i < len(runes); i++ {
+
+		if i+1 < len(runes) {
+
+			latin, ok := greekDigraphs[string(runes[i:i+2])]
+
+			if ok {
+				sb.WriteString(latin)
+				i += 1
+				continue
+			}
+		}
+
+		r := runes[i]
+		latin, ok := greekLatin[r]
+
+		switch {
+		case ok:
+			sb.WriteString(latin)
+		case unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r):
+			return "", false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String(), true
+}
+
+// transliterateHangul returns the Revised Romanization of 'text' which is expected to be written in Hangul. Each syllable is
+// romanized separately, without the sound changes that apply between syllables, which is sufficient for matching names.
+func transliterateHangul(text string) (string, bool) {
+
+	var sb strings.Builder
+
+	for _, r := range text {
+
+		switch {
+		case r >= 0xAC00 && r <= 0xD7A3:
+
+			s := int(r - 0xAC00)
+
+			sb.WriteString(hangulInitials[s/588])
+			sb.WriteString(hangulVowels[(s%588)/28])
+			sb.WriteString(hangulFinals[s%28])
+
+		case unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r):
+			return "", false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String(), true
+}
+
+// transliterateKana returns the Hepburn romanization of 'text' which is expected to be written in Hiragana or Katakana.
+// Names that also contain Kanji can not be transliterated without a dictionary.
+func transliterateKana(text string) (string, bool) {
+
+	runes := []rune(text)
+
+	// Map Katakana to Hiragana
+	for i, r := range runes {
+
+		if r >= 0x30A1 && r <= 0x30F6 {
+			runes[i] = r - 0x60
+		}
+	}
+
+	var sb strings.Builder
+	double := false
+
+	for i := 0; i < len(runes); i++ {
+
+		r := runes[i]
+
+		switch r {
+		case 'っ':
+			// The next consonant is doubled
+			double = true
+			continue
+		case 'ー':
+			// Long vowels are not marked
+			continue
+		}
+
+		latin := ""
+
+		if i+1 < len(runes) {
+
+			pair, ok := kanaLatin[string(runes[i:i+2])]
+
+			if ok {
+				latin = pair
+				i += 1
+			}
+		}
+
+		if latin == "" {
+
+			single, ok := kanaLatin[string(r)]
+
+			switch {
+			case ok:
+				latin = single
+			case unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r):
+				return "", false
+			default:
+				sb.WriteRune(r)
+				double = false
+				continue
+			}
+		}
+
+		if double {
+
+			if strings.HasPrefix(latin, "ch") {
+				sb.WriteString("t")
+			} else {
+				sb.WriteString(latin[:1])
+			}
+
+			double = false
+		}
+
+		sb.WriteString(latin)
+	}
+
+	return sb.String(), true
+}
@@ -0,0 +1,500 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github.com/paulmach/orb"
+	"github.com/whosonfirst/go-whosonfirst-placetypes"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SortField defines the property that results are ordered by.
+type SortField uint8
+
+const (
+	// SortById orders results by their Who's On First ID.
+	SortById SortField = iota
+	// SortByName orders results by their name, ignoring case and the accents of Latin letters (see `compareNames`) or, if the order
+	// has a locale, according to the conventions of that locale (see `compareLocaleNames`).
+	SortByName
+	// SortByLastModified orders results by the date they were last modified.
+	SortByLastModified
+	// SortByRelevance orders results by their relevance to a search term, most relevant first. It can not be applied in SQL.
+	SortByRelevance
+	// SortByPlacetype orders results by the rank of their placetype in the placetype hierarchy, for example countries before regions
+	// before localities.
+	SortByPlacetype
+	// SortByDistance orders results by the distance of their centroid from a point, nearest first.
+	SortByDistance
+	// SortByProperty orders results by the value of a property read from the properties table. Records without the property
+	// come first, followed by numeric values and then strings.
+	SortByProperty
+)
+
+// The rank assigned to records whose placetype is not part of the placetype hierarchy.
+const unknownPlacetypeRank int = 9999
+
+// SortOrder defines a property, and a direction, that results are ordered by.
+type SortOrder struct {
+	Field SortField
+	// Reverse the order of results, for example most recently modified or furthest away first.
+	Descending bool
+	// The point that distances are measured from when Field is `SortByDistance`.
+	Point *orb.Point
+	// The path of the property (see `ValidatePropertyPath`) that results are ordered by when Field is `SortByProperty`.
+	Property string
+	// The locale (a BCP 47 language tag, for example "sv") whose conventions are used to compare names when Field is `SortByName`.
+	// If empty names are compared the same way for every language. It is set using `SetSortLocale`.
+	Locale string
+}
+
+// ParseSortField returns the `SortField` for 'str' which is expected to be "id", "name", "lastmodified", "relevance",
+// "placetype" or "distance".
+func ParseSortField(str string) (SortField, error) {
+
+	switch strings.ToLower(str) {
+	case "id":
+		return SortById, nil
+	case "name":
+		return SortByName, nil
+	case "lastmodified":
+		return SortByLastModified, nil
+	case "relevance":
+		return SortByRelevance, nil
+	case "placetype":
+		return SortByPlacetype, nil
+	case "distance":
+		return SortByDistance, nil
+	default:
+		return 0, fmt.Errorf("Invalid sort field '%s'", str)
+	}
+}
+
+// ParseSortOrders returns the list of `SortOrder` instances defined by 'str' which is a comma-separated list of sort fields,
+// each of which may be prefixed with "-" to reverse its order. For example "placetype,-lastmodified". Fields containing a ":"
+// are property paths, for example "-wof:population". 'pt' is the point used to sort by distance and may be nil unless 'str'
+// contains "distance".
+func ParseSortOrders(str string, pt *orb.Point) ([]*SortOrder, error) {
+
+	sorts := make([]*SortOrder, 0)
+
+	for _, v := range strings.Split(str, ",") {
+
+		v = strings.TrimSpace(v)
+
+		if v == "" {
+			continue
+		}
+
+		descending := strings.HasPrefix(v, "-")
+		v = strings.TrimPrefix(v, "-")
+
+		if strings.Contains(v, ":") {
+
+			err := ValidatePropertyPath(v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			s := &SortOrder{
+				Field:      SortByProperty,
+				Descending: descending,
+				Property:   v,
+			}
+
+			sorts = append(sorts, s)
+			continue
+		}
+
+		field, err := ParseSortField(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s := &SortOrder{
+			Field:      field,
+			Descending: descending,
+		}
+
+		if field == SortByDistance {
+			s.Point = pt
+		}
+
+		sorts = append(sorts, s)
+	}
+
+	err := validateSortOrders(sorts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sorts, nil
+}
+
+// NewSortOrdersFromQuery returns the list of `SortOrder` instances defined by the `sort` parameter in 'query'. The `sort_latitude`
+// and `sort_longitude` parameters define the point used to sort by distance. For example: ?sort=distance,name&sort_latitude=45.5&sort_longitude=-73.6
+// The optional `sort_locale` parameter defines the locale used to sort by name (see `SetSortLocale`), for example: ?sort=name&sort_locale=sv
+func NewSortOrdersFromQuery(query url.Values) ([]*SortOrder, error) {
+
+	var pt *orb.Point
+
+	str_lat := query.Get("sort_latitude")
+	str_lon := query.Get("sort_longitude")
+
+	if str_lat != "" || str_lon != "" {
+
+		p, err := ParseSortPoint(str_lat, str_lon)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pt = p
+	}
+
+	sorts, err := ParseSortOrders(strings.Join(query["sort"], ","), pt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	locale := query.Get("sort_locale")
+
+	if locale != "" {
+
+		err := SetSortLocale(sorts, locale)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sorts, nil
+}
+
+// SetSortLocale sets the locale of each `SortByName` order in 'sorts' to the supported locale that best matches 'locale', which is
+// a BCP 47 language tag, for example "sv" or "de-CH". It returns an error if 'locale' is not supported or 'sorts' does not sort by name.
+func SetSortLocale(sorts []*SortOrder, locale string) error {
+
+	matched, err := matchCollationLocale(locale)
+
+	if err != nil {
+		return err
+	}
+
+	by_name := false
+
+	for _, s := range sorts {
+
+		if s.Field == SortByName {
+			s.Locale = matched
+			by_name = true
+		}
+	}
+
+	if !by_name {
+		return errors.New("A sort locale requires sorting by name")
+	}
+
+	return nil
+}
+
+// ParseSortPoint returns the point, used to sort by distance, defined by 'str_lat' and 'str_lon'.
+func ParseSortPoint(str_lat string, str_lon string) (*orb.Point, error) {
+
+	lat, err := strconv.ParseFloat(str_lat, 64)
+
+	if err != nil || lat < -90.0 || lat > 90.0 {
+		return nil, fmt.Errorf("Invalid sort latitude '%s'", str_lat)
+	}
+
+	lon, err := strconv.ParseFloat(str_lon, 64)
+
+	if err != nil || lon < -180.0 || lon > 180.0 {
+		return nil, fmt.Errorf("Invalid sort longitude '%s'", str_lon)
+	}
+
+	pt := &orb.Point{lon, lat}
+	return pt, nil
+}
+
+// validateSortOrders ensures that every `SortByDistance` order in 'sorts' has a point and every `SortByProperty` order has a valid path.
+func validateSortOrders(sorts []*SortOrder) error {
+
+	for _, s := range sorts {
+
+		if s.Field == SortByDistance && s.Point == nil {
+			return errors.New("Sorting by distance requires a point")
+		}
+
+		if s.Field == SortByProperty {
+
+			err := ValidatePropertyPath(s.Property)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if s.Locale != "" {
+
+			matched, err := matchCollationLocale(s.Locale)
+
+			if err != nil {
+				return err
+			}
+
+			if matched != s.Locale {
+				return fmt.Errorf("Unsupported sort locale '%s', did you mean '%s'", s.Locale, matched)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f SortField) String() string {
+
+	switch f {
+	case SortByName:
+		return "name"
+	case SortByLastModified:
+		return "lastmodified"
+	case SortByRelevance:
+		return "relevance"
+	case SortByPlacetype:
+		return "placetype"
+	case SortByDistance:
+		return "distance"
+	case SortByProperty:
+		return "property"
+	default:
+		return "id"
+	}
+}
+
+func (s *SortOrder) String() string {
+
+	str := s.Field.String()
+
+	if s.Field == SortByProperty {
+		str = s.Property
+	}
+
+	if s.Field == SortByDistance && s.Point != nil {
+		str = fmt.Sprintf("%s(%v,%v)", str, s.Point.Lat(), s.Point.Lon())
+	}
+
+	if s.Field == SortByName && s.Locale != "" {
+		str = fmt.Sprintf("%s(%s)", str, s.Locale)
+	}
+
+	if s.Descending {
+		return "-" + str
+	}
+
+	return str
+}
+
+// sortInSQL returns a boolean value indicating whether 'sorts' can be applied in SQL. Sorting by relevance can not.
+func sortInSQL(sorts []*SortOrder) bool {
+
+	for _, s := range sorts {
+
+		if s.Field == SortByRelevance {
+			return false
+		}
+	}
+
+	return true
+}
+
+// orderBySQL returns an ORDER BY expression, and its arguments, for 'sorts' using the columns of the spr table prefixed with 'alias'.
+// Results are always ordered by ID last so that the order is stable for records with the same values. `SortByRelevance` orders
+// are ignored.
+func orderBySQL(alias string, sorts []*SortOrder) (string, []interface{}) {
+
+	terms := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	has_id := false
+
+	for _, s := range sorts {
+
+		var expr string
+
+		switch s.Field {
+		case SortByRelevance:
+			continue
+		case SortByName:
+
+			collation := nameCollation
+
+			if s.Locale != "" {
+				collation = localeCollationName(s.Locale)
+			}
+
+			expr = fmt.Sprintf("%s.name COLLATE %s", alias, collation)
+
+		case SortByLastModified:
+			expr = fmt.Sprintf("%s.lastmodified", alias)
+		case SortByPlacetype:
+			expr = placetypeRankSQL(alias)
+		case SortByDistance:
+
+			lon := fmt.Sprintf("MIN(ABS(%s.longitude - ?), 360.0 - ABS(%s.longitude - ?)) * ?", alias, alias)
+			expr = fmt.Sprintf("((%s.latitude - ?) * (%s.latitude - ?) + (%s) * (%s))", alias, alias, lon, lon)
+
+			k := distanceScale(s.Point)
+			args = append(args, s.Point.Lat(), s.Point.Lat(), s.Point.Lon(), s.Point.Lon(), k, s.Point.Lon(), s.Point.Lon(), k)
+
+		case SortByProperty:
+			expr = fmt.Sprintf("%s COLLATE %s", propertyValueSQL(alias), nameCollation)
+			args = append(args, propertyJSONPath(s.Property))
+
+		default:
+			expr = fmt.Sprintf("CAST(%s.id AS INTEGER)", alias)
+			has_id = true
+		}
+
+		direction := "ASC"
+
+		if s.Descending {
+			direction = "DESC"
+		}
+
+		terms = append(terms, fmt.Sprintf("%s %s", expr, direction))
+
+		if has_id {
+			break
+		}
+	}
+
+	if !has_id {
+		terms = append(terms, fmt.Sprintf("CAST(%s.id AS INTEGER) ASC", alias))
+	}
+
+	return strings.Join(terms, ", "), args
+}
+
+// placetypeRankSQL returns a SQL expression for the rank of the placetype column prefixed with 'alias' (see `placetypeRank`).
+func placetypeRankSQL(alias string) string {
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("CASE %s.placetype", alias))
+
+	all, err := placetypes.Placetypes()
+
+	if err == nil {
+
+		for _, pt := range all {
+			name := strings.ReplaceAll(pt.Name, "'", "''")
+			sb.WriteString(fmt.Sprintf(" WHEN '%s' THEN %d", name, placetypeRank(pt.Name)))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf(" ELSE %d END", unknownPlacetypeRank))
+	return sb.String()
+}
+
+// placetypeRank returns the number of ancestors 'name' has in the placetype hierarchy or `unknownPlacetypeRank` if 'name' is
+// not a valid placetype.
+func placetypeRank(name string) int {
+
+	depth := placetypeDepth(name)
+
+	if depth < 0 {
+		return unknownPlacetypeRank
+	}
+
+	return depth
+}
+
+// distanceScale returns the factor that differences in longitude are multiplied by, at the latitude of 'pt', so that they
+// are comparable to differences in latitude.
+func distanceScale(pt *orb.Point) float64 {
+	return math.Cos(pt.Lat() * math.Pi / 180.0)
+}
+
+// sortDistance returns a value that increases with the distance between 'pt' and 'lat', 'lon'. It is an equirectangular
+// approximation, sufficient for ordering results, that is calculated the same way as the SQL expression in `orderBySQL`.
+func sortDistance(pt *orb.Point, lat float64, lon float64) float64 {
+
+	d_lat := lat - pt.Lat()
+	d_lon := math.Abs(lon - pt.Lon())
+	d_lon = math.Min(d_lon, 360.0-d_lon) * distanceScale(pt)
+
+	return d_lat*d_lat + d_lon*d_lon
+}
+
+// compareRankedResults compares 'a' and 'b' in ascending order of the field defined by 's'. It returns -1, 0 or 1.
+func compareRankedResults(a *rankedResult, b *rankedResult, s *SortOrder) int {
+
+	switch s.Field {
+	case SortByRelevance:
+		// More relevant results first
+		return compareFloat64(b.Score, a.Score)
+	case SortByName:
+
+		if s.Locale != "" {
+			return compareLocaleNames(s.Locale, a.SPR.Name(), b.SPR.Name())
+		}
+
+		return compareNames(a.SPR.Name(), b.SPR.Name())
+	case SortByLastModified:
+		return compareInt64(a.SPR.LastModified(), b.SPR.LastModified())
+	case SortByPlacetype:
+		return compareInt64(int64(placetypeRank(a.SPR.Placetype())), int64(placetypeRank(b.SPR.Placetype())))
+	case SortByDistance:
+		d_a := sortDistance(s.Point, a.SPR.Latitude(), a.SPR.Longitude())
+		d_b := sortDistance(s.Point, b.SPR.Latitude(), b.SPR.Longitude())
+		return compareFloat64(d_a, d_b)
+	case SortByProperty:
+		// Property values are added by loadRankedProperties
+		return compareProperties(a.properties[s.Property], b.properties[s.Property])
+	default:
+		return compareInt64(parseSortId(a.SPR.Id()), parseSortId(b.SPR.Id()))
+	}
+}
+
+func parseSortId(str_id string) int64 {
+
+	id, err := strconv.ParseInt(str_id, 10, 64)
+
+	if err != nil {
+		return -1
+	}
+
+	return id
+}
+
+func compareInt64(a int64, b int64) int {
+
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat64(a float64, b float64) int {
+
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
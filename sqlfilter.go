@@ -0,0 +1,467 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/whosonfirst/go-whosonfirst-flags"
+	"github.com/whosonfirst/go-whosonfirst-flags/existential"
+	"github.com/whosonfirst/go-whosonfirst-flags/geometry"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SQLFilter is implemented by filters whose constraints can be applied to the spr table in SQL, rather than to each result
+// after it has been retrieved. SQLFilter instances can be passed to any method that accepts a `filter.Filter` instance.
+type SQLFilter interface {
+	ResultFilter
+	// WhereSQL returns a SQL expression, and its arguments, that is true for the rows in the spr table that pass the filter.
+	// Column names are prefixed with 'alias'. If the filter has no constraints the expression is an empty string.
+	WhereSQL(alias string) (string, []interface{})
+	String() string
+}
+
+var re_country = regexp.MustCompile(`^[A-Z]{2}$`)
+
+var re_repo = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// columnFilter matches records whose value for a given column in the spr table is (or is not) one of a list of values.
+type columnFilter struct {
+	nullFilter
+	column  string
+	include []string
+	exclude []string
+	value   func(wof_spr.StandardPlacesResult) string
+}
+
+// CountryFilter restricts results to, or excludes results from, one or more countries using the `country` column of the spr table.
+type CountryFilter struct {
+	*columnFilter
+}
+
+// RepoFilter restricts results to, or excludes results from, one or more repositories using the `repo` column of the spr table.
+type RepoFilter struct {
+	*columnFilter
+}
+
+// NewCountryFilter returns a new `CountryFilter` for 'codes' which are ISO 3166-1 alpha-2 country codes. Codes prefixed
+// with "-" are excluded, for example NewCountryFilter("CA", "-US"). Multiple codes may also be separated by commas.
+func NewCountryFilter(codes ...string) (*CountryFilter, error) {
+
+	normalize := func(code string) (string, error) {
+
+		code = strings.ToUpper(code)
+
+		if !re_country.MatchString(code) {
+			return "", fmt.Errorf("Invalid country code '%s'", code)
+		}
+
+		return code, nil
+	}
+
+	value := func(s wof_spr.StandardPlacesResult) string {
+		return s.Country()
+	}
+
+	cf, err := newColumnFilter("country", codes, normalize, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &CountryFilter{
+		columnFilter: cf,
+	}
+
+	return f, nil
+}
+
+// NewRepoFilter returns a new `RepoFilter` for 'repos' which are repository names, for example "whosonfirst-data-admin-fr".
+// Names prefixed with "-" are excluded. Multiple names may also be separated by commas.
+func NewRepoFilter(repos ...string) (*RepoFilter, error) {
+
+	normalize := func(repo string) (string, error) {
+
+		if !re_repo.MatchString(repo) {
+			return "", fmt.Errorf("Invalid repo '%s'", repo)
+		}
+
+		return repo, nil
+	}
+
+	value := func(s wof_spr.StandardPlacesResult) string {
+		return s.Repo()
+	}
+
+	cf, err := newColumnFilter("repo", repos, normalize, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &RepoFilter{
+		columnFilter: cf,
+	}
+
+	return f, nil
+}
+
+// NewFiltersFromQuery returns the filters defined by 'query'. This includes the `filter.SPRFilter` parameters (placetype,
+// is_current and so on) along with `country` and `repo` parameters which may be repeated, contain comma-separated values
+// and be prefixed with "-" to exclude a value. For example: ?placetype=locality&country=CA,FR&repo=-whosonfirst-data-admin-us
+// The `existed`, `incepted` and `ceased` parameters are EDTF dates used to create `TemporalFilter` instances whose mode is
+// defined by the `temporal_mode` parameter. The `modified_since` and `modified_before` parameters are Unix timestamps or
+// RFC 3339 dates used to create a `ModifiedFilter` instance. The `parent_id` and `ancestor_id` parameters are lists of
+// Who's On First IDs used to create `ParentFilter` and `AncestorFilter` instances. Each `property` parameter is an expression,
+// for example ?property=wof:population>1000000, used to create a `PropertyFilter` instance.
+func NewFiltersFromQuery(query url.Values) ([]filter.Filter, error) {
+
+	spr_f, err := filter.NewSPRFilterFromQuery(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []filter.Filter{
+		spr_f,
+	}
+
+	countries := query["country"]
+
+	if len(countries) > 0 {
+
+		f, err := NewCountryFilter(countries...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	repos := query["repo"]
+
+	if len(repos) > 0 {
+
+		f, err := NewRepoFilter(repos...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	parent_ids := query["parent_id"]
+
+	if len(parent_ids) > 0 {
+
+		f, err := NewParentFilter(parent_ids...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	ancestor_ids := query["ancestor_id"]
+
+	if len(ancestor_ids) > 0 {
+
+		f, err := NewAncestorFilter(ancestor_ids...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	for _, expr := range query["property"] {
+
+		f, err := NewPropertyFilter(expr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	str_since := query.Get("modified_since")
+	str_before := query.Get("modified_before")
+
+	if str_since != "" || str_before != "" {
+
+		since, err := ParseModifiedTime(str_since)
+
+		if err != nil {
+			return nil, err
+		}
+
+		before, err := ParseModifiedTime(str_before)
+
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := NewModifiedFilter(since, before)
+
+		if err != nil {
+			return nil, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	mode, err := ParseTemporalMode(query.Get("temporal_mode"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, constraint := range []TemporalConstraint{ExistedConstraint, InceptedConstraint, CeasedConstraint} {
+
+		for _, edtf_str := range query[constraint.String()] {
+
+			f, err := NewTemporalFilter(constraint, edtf_str, mode)
+
+			if err != nil {
+				return nil, err
+			}
+
+			filters = append(filters, f)
+		}
+	}
+
+	return filters, nil
+}
+
+func newColumnFilter(column string, values []string, normalize func(string) (string, error), value func(wof_spr.StandardPlacesResult) string) (*columnFilter, error) {
+
+	f := &columnFilter{
+		column:  column,
+		include: make([]string, 0),
+		exclude: make([]string, 0),
+		value:   value,
+	}
+
+	for _, raw := range values {
+
+		for _, v := range strings.Split(raw, ",") {
+
+			v = strings.TrimSpace(v)
+
+			if v == "" {
+				continue
+			}
+
+			exclude := strings.HasPrefix(v, "-")
+			v = strings.TrimPrefix(v, "-")
+
+			v, err := normalize(v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if exclude {
+				f.exclude = append(f.exclude, v)
+			} else {
+				f.include = append(f.include, v)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+func (f *columnFilter) WhereSQL(alias string) (string, []interface{}) {
+
+	clauses := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if len(f.include) > 0 {
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(f.include)), ",")
+		clauses = append(clauses, fmt.Sprintf("%s.%s IN (%s)", alias, f.column, placeholders))
+
+		for _, v := range f.include {
+			args = append(args, v)
+		}
+	}
+
+	if len(f.exclude) > 0 {
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(f.exclude)), ",")
+		clauses = append(clauses, fmt.Sprintf("%s.%s NOT IN (%s)", alias, f.column, placeholders))
+
+		for _, v := range f.exclude {
+			args = append(args, v)
+		}
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+func (f *columnFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+
+	v := f.value(s)
+
+	if len(f.include) > 0 && !stringInList(v, f.include) {
+		return false
+	}
+
+	if stringInList(v, f.exclude) {
+		return false
+	}
+
+	return true
+}
+
+func (f *columnFilter) String() string {
+
+	values := make([]string, 0)
+	values = append(values, f.include...)
+
+	for _, v := range f.exclude {
+		values = append(values, "-"+v)
+	}
+
+	return fmt.Sprintf("%s=%s", f.column, strings.Join(values, ","))
+}
+
+func stringInList(str string, list []string) bool {
+
+	for _, candidate := range list {
+
+		if str == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sprSQLFilter applies the constraints of a `filter.SPRFilter` instance in SQL using the placetype and existential flag columns of the spr table.
+type sprSQLFilter struct {
+	*filter.SPRFilter
+}
+
+// newSPRSQLFilter returns a new `sprSQLFilter` for 'f' and a boolean value indicating whether all of its constraints can be
+// applied in SQL. Alternate geometry constraints can not since only default records are queried.
+func newSPRSQLFilter(f *filter.SPRFilter) (*sprSQLFilter, bool) {
+
+	if f.AlternateGeometry != nil {
+
+		_, is_null := f.AlternateGeometry.(*geometry.NullAlternateGeometryFlag)
+
+		if !is_null && f.AlternateGeometry.IsAlternateGeometry() {
+			return nil, false
+		}
+	}
+
+	for _, fl := range f.AlternateGeometries {
+
+		_, is_null := fl.(*geometry.NullAlternateGeometryFlag)
+
+		if !is_null {
+			return nil, false
+		}
+	}
+
+	sql_f := &sprSQLFilter{
+		SPRFilter: f,
+	}
+
+	return sql_f, true
+}
+
+func (f *sprSQLFilter) WhereSQL(alias string) (string, []interface{}) {
+
+	clauses := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	placetypes := make([]interface{}, 0)
+	any_placetype := false
+
+	for _, fl := range f.Placetypes {
+
+		if fl.Placetype() == "" {
+			any_placetype = true
+			break
+		}
+
+		placetypes = append(placetypes, fl.Placetype())
+	}
+
+	if !any_placetype {
+		clauses = append(clauses, inListSQL(alias, "placetype", placetypes))
+		args = append(args, placetypes...)
+	}
+
+	existential_flags := [][]flags.ExistentialFlag{
+		f.Current,
+		f.Deprecated,
+		f.Ceased,
+		f.Superseded,
+		f.Superseding,
+	}
+
+	existential_columns := []string{
+		"is_current",
+		"is_deprecated",
+		"is_ceased",
+		"is_superseded",
+		"is_superseding",
+	}
+
+	for i, possible := range existential_flags {
+
+		values := make([]interface{}, 0)
+		any_value := false
+
+		for _, fl := range possible {
+
+			_, is_null := fl.(*existential.NullFlag)
+
+			if is_null {
+				any_value = true
+				break
+			}
+
+			values = append(values, fl.Flag())
+		}
+
+		if any_value {
+			continue
+		}
+
+		clauses = append(clauses, inListSQL(alias, existential_columns[i], values))
+		args = append(args, values...)
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+func (f *sprSQLFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+	return filter.FilterSPR(f.SPRFilter, s) == nil
+}
+
+func (f *sprSQLFilter) String() string {
+	key, _ := filterCacheKey(f.SPRFilter)
+	return key
+}
+
+// inListSQL returns a SQL expression that is true if 'column' is one of 'values'. An empty list of values matches nothing.
+func inListSQL(alias string, column string, values []interface{}) string {
+
+	if len(values) == 0 {
+		return "0"
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+	return fmt.Sprintf("%s.%s IN (%s)", alias, column, placeholders)
+}
@@ -0,0 +1,526 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-pagination"
+	"github.com/whosonfirst/go-whosonfirst-search/filter"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-sqlite-spr"
+	"strings"
+	"time"
+)
+
+// StructuredQuery is a query for a place defined by the names of itself and its ancestors, each of which is matched against the
+// records with the corresponding placetypes. All the fields are optional but at least one must be defined.
+type StructuredQuery struct {
+	// The name of a neighbourhood, matched against neighbourhoods, macrohoods, microhoods and boroughs.
+	Neighbourhood string `json:"neighbourhood,omitempty"`
+	// The name of a locality, matched against localities and localadmins.
+	Locality string `json:"locality,omitempty"`
+	// The name of a county, matched against counties and macrocounties.
+	County string `json:"county,omitempty"`
+	// The name of a region, matched against regions and macroregions.
+	Region string `json:"region,omitempty"`
+	// The name of a country, matched against countries and dependencies.
+	Country string `json:"country,omitempty"`
+}
+
+// structuredField is a single, non-empty, field of a `StructuredQuery`.
+type structuredField struct {
+	// The name of the field, for example "locality".
+	Name string
+	// The search term for the field.
+	Term string
+	// The placetypes of the records that Term is matched against.
+	Placetypes []string
+}
+
+// The placetypes matched by each field of a `StructuredQuery`, from most to least specific.
+var structuredPlacetypes = []*structuredField{
+	&structuredField{Name: "neighbourhood", Placetypes: []string{"neighbourhood", "macrohood", "microhood", "borough"}},
+	&structuredField{Name: "locality", Placetypes: []string{"locality", "localadmin"}},
+	&structuredField{Name: "county", Placetypes: []string{"county", "macrocounty"}},
+	&structuredField{Name: "region", Placetypes: []string{"region", "macroregion"}},
+	&structuredField{Name: "country", Placetypes: []string{"country", "dependency"}},
+}
+
+// fields returns the non-empty fields of 'q', from most to least specific.
+func (q *StructuredQuery) fields() []*structuredField {
+
+	terms := map[string]string{
+		"neighbourhood": q.Neighbourhood,
+		"locality":      q.Locality,
+		"county":        q.County,
+		"region":        q.Region,
+		"country":       q.Country,
+	}
+
+	fields := make([]*structuredField, 0)
+
+	for _, f := range structuredPlacetypes {
+
+		term := strings.TrimSpace(terms[f.Name])
+
+		if term == "" {
+			continue
+		}
+
+		fields = append(fields, &structuredField{
+			Name:       f.Name,
+			Term:       term,
+			Placetypes: f.Placetypes,
+		})
+	}
+
+	return fields
+}
+
+// String returns the non-empty fields of 'q' as a list of "{FIELD}={TERM}" pairs separated by spaces, for example
+// "locality=Montreal region=Quebec".
+func (q *StructuredQuery) String() string {
+
+	parts := make([]string, 0)
+
+	for _, f := range q.fields() {
+		parts = append(parts, fmt.Sprintf("%s=%s", f.Name, f.Term))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// QueryStructured returns the records matching 'q' and 'filters'. Each field of 'q' is matched against the names of the records with
+// the corresponding placetypes and a record matching one field is only returned if, for every less specific field, it has an ancestor
+// matching that field. For example a query for the locality "Springfield" and the region "Illinois" returns the Springfields whose
+// ancestors include a region named Illinois, followed by the regions named Illinois. Records matching more specific fields are always
+// returned first, in descending order of relevance unless 'opts' defines another order. Search terms are matched according to the
+// match mode in 'opts' but phonetic codes are never matched. Ancestors are read from the ancestors table which must be present in the
+// database if 'q' has more than one field. Results are not cached. If 'opts' is nil the default query options are used.
+func (ftdb *SQLiteFullTextDatabase) QueryStructured(ctx context.Context, q *StructuredQuery, opts *QueryOptions, filters ...filter.Filter) (wof_spr.StandardPlacesResults, pagination.Results, error) {
+
+	if opts == nil {
+
+		default_opts, err := DefaultQueryOptions()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		opts = default_opts
+	}
+
+	ev := &QueryEvent{
+		Database: ftdb.db.DSN(),
+		Term:     q.String(),
+	}
+
+	t1 := time.Now()
+
+	ranked, err := ftdb.queryStructuredWithEvent(ctx, q, opts, ev, filters...)
+
+	ev.Latency = time.Since(t1)
+	ev.Results = len(ranked)
+	ev.Error = err
+
+	for _, o := range ftdb.observers {
+		o.ObserveQuery(ctx, ev)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page, pg, err := paginateRankedResults(ranked, opts.Pagination)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := resultsFromRankedResults(ctx, ranked, page, opts)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, pg, nil
+}
+
+// queryStructuredWithEvent does the work of QueryStructured recording the details of the query in 'ev'.
+func (ftdb *SQLiteFullTextDatabase) queryStructuredWithEvent(ctx context.Context, q *StructuredQuery, opts *QueryOptions, ev *QueryEvent, filters ...filter.Filter) ([]*rankedResult, error) {
+
+	fields := q.fields()
+
+	if len(fields) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	err := validateSortOrders(opts.Sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 1 {
+
+		err := ftdb.requireTables(ctx, conn, "structured queries", ftdb.ancestors_table)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sql_filters, go_filters := splitFilters(filters...)
+
+	for _, f := range sql_filters {
+		ev.SQLFilters = append(ev.SQLFilters, describeFilter(f))
+	}
+
+	for _, f := range go_filters {
+		ev.GoFilters = append(ev.GoFilters, describeFilter(f))
+	}
+
+	expanders := ftdb.tokenExpanders(opts)
+
+	t_match := time.Now()
+
+	// The IDs matching each field, in the same order as 'fields'
+	candidates := make([][]int64, len(fields))
+	matches := make([]string, len(fields))
+
+	for i, f := range fields {
+
+		match, err := matchExpression(f.Term, opts.MatchMode, expanders...)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s, %w", f.Name, err)
+		}
+
+		match_q, match_args := ftdb.structuredMatchSQL(match, f.Placetypes)
+		ev.SQL = match_q
+
+		ids, err := ftdb.structuredMatches(ctx, conn, match_q, match_args...)
+
+		if err != nil {
+			return nil, wrapMatchError(f.Term, err)
+		}
+
+		candidates[i] = ids
+		matches[i] = fmt.Sprintf("%s:%s", f.Name, match)
+
+		ev.RowsMatched += len(ids)
+	}
+
+	ev.Match = strings.Join(matches, " ")
+	ev.addStage("match", t_match)
+
+	t_hierarchy := time.Now()
+
+	// The IDs matching each field, keyed by ID
+	matching := make([]map[int64]bool, len(fields))
+
+	for i, ids := range candidates {
+
+		matching[i] = make(map[int64]bool)
+
+		for _, id := range ids {
+			matching[i][id] = true
+		}
+	}
+
+	// Ancestors are only needed for records matching fields that have a less specific field
+	all_ids := make([]int64, 0)
+
+	for i, ids := range candidates {
+
+		if i < len(fields)-1 {
+			all_ids = append(all_ids, ids...)
+		}
+	}
+
+	ancestors, err := ftdb.retrieveAncestors(ctx, conn, all_ids)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve ancestors, %w", err)
+	}
+
+	ev.addStage("hierarchy", t_hierarchy)
+
+	t_fetch := time.Now()
+
+	ranked := make([]*rankedResult, 0)
+	seen := make(map[int64]bool)
+
+	for i, ids := range candidates {
+
+		for _, id := range ids {
+
+			// A record may match more than one field, for example a city-state, in which case its most specific match is used
+			if seen[id] {
+				continue
+			}
+
+			if !structuredCoherent(id, ancestors[id], matching[i+1:]) {
+				continue
+			}
+
+			spr_r, err := spr.RetrieveSPR(ctx, ftdb.db, ftdb.spr_table, id, "")
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to retrieve SPR for %d, %w", id, err)
+			}
+
+			if !matchesFilters(spr_r, go_filters...) {
+				continue
+			}
+
+			ok, err := ftdb.matchesSQLFilters(ctx, conn, id, sql_filters...)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to apply filters to %d, %w", id, err)
+			}
+
+			if !ok {
+				continue
+			}
+
+			seen[id] = true
+
+			ranked = append(ranked, &rankedResult{
+				SPR:    spr_r,
+				Score:  structuredScore(i, len(fields), relevance(fields[i].Term, spr_r)),
+				Index:  len(ranked),
+				source: ftdb,
+			})
+		}
+	}
+
+	ev.SPRFetchTime = time.Since(t_fetch)
+	ev.addStage("fetch", t_fetch)
+
+	sort_properties := sortPropertyPaths(opts.Sort)
+
+	if len(sort_properties) > 0 {
+
+		t_properties := time.Now()
+
+		err := loadRankedProperties(ctx, ranked, sort_properties)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ev.addStage("properties", t_properties)
+	}
+
+	t_sort := time.Now()
+
+	sortRankedResults(ranked, opts.Sort...)
+
+	ev.addStage("sort", t_sort)
+
+	return ranked, nil
+}
+
+// structuredMatchSQL returns the SQL statement, and its arguments, used to find the records with any of 'placetypes', or any placetype
+// if empty, whose names match the full-text MATCH expression 'match'.
+func (ftdb *SQLiteFullTextDatabase) structuredMatchSQL(match string, placetypes []string) (string, []interface{}) {
+
+	q := fmt.Sprintf("SELECT id FROM %s WHERE names_all MATCH ?", ftdb.search_table.Name())
+
+	if len(placetypes) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(placetypes)), ",")
+		q = fmt.Sprintf("%s AND placetype IN (%s)", q, placeholders)
+	}
+
+	args := []interface{}{match}
+
+	for _, pt := range placetypes {
+		args = append(args, pt)
+	}
+
+	return q, args
+}
+
+// structuredMatches returns the distinct IDs returned by 'q' which is expected to select a single ID column.
+func (ftdb *SQLiteFullTextDatabase) structuredMatches(ctx context.Context, conn *sql.DB, q string, args ...interface{}) ([]int64, error) {
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	seen := make(map[int64]bool)
+
+	for rows.Next() {
+
+		var id int64
+
+		err := rows.Scan(&id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[id] {
+			ids = append(ids, id)
+			seen[id] = true
+		}
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// retrieveAncestors returns the IDs of the ancestors of each of 'ids', read from the ancestors table, keyed by ID.
+func (ftdb *SQLiteFullTextDatabase) retrieveAncestors(ctx context.Context, conn *sql.DB, ids []int64) (map[int64][]int64, error) {
+
+	ancestors := make(map[int64][]int64)
+
+	for start := 0; start < len(ids); start += maxQueryParameters {
+
+		end := start + maxQueryParameters
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		batch := ids[start:end]
+		args := make([]interface{}, len(batch))
+
+		for i, id := range batch {
+			args[i] = id
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		q := fmt.Sprintf("SELECT id, ancestor_id FROM %s WHERE id IN (%s)", ftdb.ancestors_table.Name(), placeholders)
+
+		err := ftdb.retrieveAncestorsBatch(ctx, conn, q, args, ancestors)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ancestors, nil
+}
+
+// retrieveAncestorsBatch adds the ancestors returned by 'q', which is expected to select an ID and an ancestor ID, to 'ancestors'.
+func (ftdb *SQLiteFullTextDatabase) retrieveAncestorsBatch(ctx context.Context, conn *sql.DB, q string, args []interface{}, ancestors map[int64][]int64) error {
+
+	rows, err := conn.QueryContext(ctx, q, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var id int64
+		var ancestor_id int64
+
+		err := rows.Scan(&id, &ancestor_id)
+
+		if err != nil {
+			return err
+		}
+
+		ancestors[id] = append(ancestors[id], ancestor_id)
+	}
+
+	return rows.Err()
+}
+
+// structuredCoherent returns a boolean value indicating whether the record 'id', whose ancestors are 'ancestors', has an ancestor
+// in each of 'matching' which are the IDs matching the less specific fields of a `StructuredQuery`.
+func structuredCoherent(id int64, ancestors []int64, matching []map[int64]bool) bool {
+
+	for _, ids := range matching {
+
+		found := false
+
+		for _, a := range ancestors {
+
+			if a != id && ids[a] {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// structuredScore returns the relevance of a record matching the field at 'position', counting from the most specific, of 'count'
+// fields given that it has 'relevance' for the term of that field. Each field has its own band of scores so that records matching
+// more specific fields are always ranked first.
+func structuredScore(position int, count int, relevance float64) float64 {
+	return (float64(count-position-1) + relevance) / float64(count)
+}
+
+// ParseStructuredQuery returns a new `StructuredQuery` for 'str' which is a list of "{FIELD}={TERM}" pairs separated by semi-colons,
+// for example "locality=Montreal;region=Quebec". Valid fields are neighbourhood, locality, county, region and country.
+func ParseStructuredQuery(str string) (*StructuredQuery, error) {
+
+	q := new(StructuredQuery)
+
+	for _, pair := range strings.Split(str, ";") {
+
+		pair = strings.TrimSpace(pair)
+
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid structured query field '%s'", pair)
+		}
+
+		term := strings.TrimSpace(parts[1])
+
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "neighbourhood", "neighborhood":
+			q.Neighbourhood = term
+		case "locality":
+			q.Locality = term
+		case "county":
+			q.County = term
+		case "region":
+			q.Region = term
+		case "country":
+			q.Country = term
+		default:
+			return nil, fmt.Errorf("Invalid structured query field '%s'", parts[0])
+		}
+	}
+
+	if len(q.fields()) == 0 {
+		return nil, errors.New("Structured query must define one or more fields")
+	}
+
+	return q, nil
+}
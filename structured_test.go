@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+)
+
+// springfieldFeatures returns two regions, Illinois and Massachusetts, and the Springfields in each of them.
+func springfieldFeatures() [][]byte {
+
+	region := func(id int64, name string, lat float64, lon float64) []byte {
+
+		return testFeature(id, name, lat, lon, map[string]interface{}{
+			"wof:placetype": "region",
+			"wof:country":   "US",
+			"wof:hierarchy": []map[string]int64{
+				{"region_id": id, "country_id": 85633793},
+			},
+		})
+	}
+
+	locality := func(id int64, name string, lat float64, lon float64, region_id int64) []byte {
+
+		return testFeature(id, name, lat, lon, map[string]interface{}{
+			"wof:country":   "US",
+			"wof:parent_id": region_id,
+			"wof:belongsto": []int64{region_id, 85633793},
+			"wof:hierarchy": []map[string]int64{
+				{"locality_id": id, "region_id": region_id, "country_id": 85633793},
+			},
+		})
+	}
+
+	return [][]byte{
+		region(10, "Illinois", 40.00, -89.25),
+		region(20, "Massachusetts", 42.26, -71.80),
+		locality(100, "Springfield", 39.80, -89.64, 10),
+		locality(101, "Springfield Center", 39.78, -89.65, 10),
+		locality(200, "Springfield", 42.10, -72.59, 20),
+	}
+}
+
+// structuredIds returns the IDs of the results for 'str', which is parsed using `ParseStructuredQuery`, in the order they are returned.
+func structuredIds(t *testing.T, ftdb *SQLiteFullTextDatabase, str string) []string {
+
+	t.Helper()
+
+	q, err := ParseStructuredQuery(str)
+
+	if err != nil {
+		t.Fatalf("Failed to parse structured query '%s', %v", str, err)
+	}
+
+	r, _, err := ftdb.QueryStructured(context.Background(), q, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to query '%s', %v", str, err)
+	}
+
+	ids := make([]string, 0)
+
+	for _, s := range r.Results() {
+		ids = append(ids, s.Id())
+	}
+
+	return ids
+}
+
+func TestQueryStructured(t *testing.T) {
+
+	features := springfieldFeatures()
+
+	ftdb := newTestDatabase(t, "", features...)
+	indexAncestors(t, ftdb, features...)
+
+	tests := []struct {
+		Query    string
+		Expected []string
+	}{
+		// The exact match in the region first, then the prefix match in the region, then the region itself
+		{Query: "locality=Springfield;region=Illinois", Expected: []string{"100", "101", "10"}},
+		{Query: "locality=Springfield;region=Massachusetts", Expected: []string{"200", "20"}},
+		// Records are only matched against the fields for their placetype
+		{Query: "region=Springfield", Expected: []string{}},
+		{Query: "locality=Illinois", Expected: []string{}},
+		// Springfields without an ancestor matching the region are excluded
+		{Query: "locality=Springfield;region=Ohio", Expected: []string{}},
+	}
+
+	for _, test := range tests {
+
+		ids := structuredIds(t, ftdb, test.Query)
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected '%s' to return %v but got %v", test.Query, test.Expected, ids)
+		}
+	}
+
+	ids := sortedIds(structuredIds(t, ftdb, "locality=Springfield"))
+	expected := []string{"100", "101", "200"}
+
+	if !equalIds(ids, expected) {
+		t.Errorf("Expected every Springfield %v but got %v", expected, ids)
+	}
+}
+
+func TestQueryStructuredWithoutAncestors(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "", springfieldFeatures()...)
+
+	q, err := ParseStructuredQuery("locality=Springfield;region=Illinois")
+
+	if err != nil {
+		t.Fatalf("Failed to parse structured query, %v", err)
+	}
+
+	_, _, err = ftdb.QueryStructured(context.Background(), q, nil)
+
+	if err == nil {
+		t.Errorf("Expected a query with more than one field to fail without an ancestors table")
+	}
+}
@@ -0,0 +1,453 @@
+package sqlite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The maximum number of alternative phrases a single search term is expanded in to. Terms containing several words with
+// synonyms, for example "N-St-Mt", would otherwise produce an unreasonably large MATCH expression.
+const maxSynonymVariants int = 16
+
+// defaultSynonyms is the built-in synonym dictionary, in the format read by `NewSynonymDictionaryFromReader`. Single-letter
+// abbreviations, for example "n" for "north", are only expanded when they are followed by a period (see `wordSynonyms`).
+const defaultSynonyms string = `
+# English
+eng: saint, st
+eng: saints, sts
+eng: mount, mt
+eng: mountain, mtn
+eng: fort, ft
+eng: port, pt
+eng: point, pt
+eng: north, n
+eng: south, s
+eng: east, e
+eng: west, w
+eng: northeast, ne
+eng: northwest, nw
+eng: southeast, se
+eng: southwest, sw
+eng: heights, hts
+eng: springs, spgs
+eng: township, twp
+eng: junction, jct
+eng: center, centre, ctr
+eng: street, st
+eng: avenue, ave
+eng: road, rd
+eng: lake, lk
+
+# French
+fra: saint, st
+fra: sainte, ste
+fra: saints, sts
+fra: saintes, stes
+fra: mont, mt
+fra: fort, ft
+fra: nord, n
+fra: sud, s
+fra: est, e
+fra: ouest, o
+fra: boulevard, boul, bd
+fra: avenue, av
+`
+
+// SynonymDictionary maps words to their synonyms and abbreviations, for example "saint" and "st", for one or more languages.
+type SynonymDictionary struct {
+	// Words and the groups of equivalent words they belong to, keyed by (ISO 639-3) language code
+	lookup map[string]map[string][]string
+}
+
+// NewSynonymDictionary returns a new, empty, `SynonymDictionary` instance.
+func NewSynonymDictionary() *SynonymDictionary {
+
+	d := &SynonymDictionary{
+		lookup: make(map[string]map[string][]string),
+	}
+
+	return d
+}
+
+// DefaultSynonymDictionary returns a new `SynonymDictionary` instance with common English and French synonyms and abbreviations.
+func DefaultSynonymDictionary() (*SynonymDictionary, error) {
+	return NewSynonymDictionaryFromReader(strings.NewReader(defaultSynonyms))
+}
+
+// NewSynonymDictionaryFromFile returns a new `SynonymDictionary` instance read from 'path' (see `NewSynonymDictionaryFromReader`).
+func NewSynonymDictionaryFromFile(path string) (*SynonymDictionary, error) {
+
+	fh, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fh.Close()
+
+	d, err := NewSynonymDictionaryFromReader(fh)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read synonyms from %s, %w", path, err)
+	}
+
+	return d, nil
+}
+
+// NewSynonymDictionaryFromReader returns a new `SynonymDictionary` instance read from 'r'. Each line is a language code followed
+// by a colon and a comma-separated list of equivalent words, for example "eng: saint, st". Blank lines and lines starting with
+// "#" are ignored.
+func NewSynonymDictionaryFromReader(r io.Reader) (*SynonymDictionary, error) {
+
+	d := NewSynonymDictionary()
+
+	scanner := bufio.NewScanner(r)
+	line_no := 0
+
+	for scanner.Scan() {
+
+		line_no += 1
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid synonyms at line %d, missing language", line_no)
+		}
+
+		err := d.Add(strings.TrimSpace(parts[0]), strings.Split(parts[1], ",")...)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid synonyms at line %d, %w", line_no, err)
+		}
+	}
+
+	err := scanner.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Add records that 'words' are equivalent in 'lang'. Words are compared ignoring case and may be listed in more than one
+// group, for example "st" is both "saint" and "street".
+func (d *SynonymDictionary) Add(lang string, words ...string) error {
+
+	lang = strings.ToLower(lang)
+
+	if lang == "" || !isSynonymWord(lang) {
+		return fmt.Errorf("Invalid language '%s'", lang)
+	}
+
+	group := make([]string, 0)
+
+	for _, w := range words {
+
+		w = strings.ToLower(strings.TrimSpace(w))
+
+		if w == "" {
+			continue
+		}
+
+		if !isSynonymWord(w) {
+			return fmt.Errorf("Invalid word '%s', synonyms must be single words", w)
+		}
+
+		if !stringInList(w, group) {
+			group = append(group, w)
+		}
+	}
+
+	if len(group) < 2 {
+		return fmt.Errorf("Synonyms for '%s' must list at least two words", strings.Join(group, ","))
+	}
+
+	_, ok := d.lookup[lang]
+
+	if !ok {
+		d.lookup[lang] = make(map[string][]string)
+	}
+
+	for _, w := range group {
+
+		for _, other := range group {
+
+			if other != w && !stringInList(other, d.lookup[lang][w]) {
+				d.lookup[lang][w] = append(d.lookup[lang][w], other)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Merge adds all the synonyms in 'other' to the dictionary.
+func (d *SynonymDictionary) Merge(other *SynonymDictionary) {
+
+	for lang, words := range other.lookup {
+
+		_, ok := d.lookup[lang]
+
+		if !ok {
+			d.lookup[lang] = make(map[string][]string)
+		}
+
+		for w, synonyms := range words {
+
+			for _, s := range synonyms {
+
+				if !stringInList(s, d.lookup[lang][w]) {
+					d.lookup[lang][w] = append(d.lookup[lang][w], s)
+				}
+			}
+		}
+	}
+}
+
+// Languages returns the sorted list of languages in the dictionary.
+func (d *SynonymDictionary) Languages() []string {
+
+	languages := make([]string, 0)
+
+	for lang, _ := range d.lookup {
+		languages = append(languages, lang)
+	}
+
+	sort.Strings(languages)
+	return languages
+}
+
+// Synonyms returns the sorted list of words equivalent to 'word' in any of 'languages' or, if empty, in any language.
+func (d *SynonymDictionary) Synonyms(word string, languages ...string) []string {
+
+	word = strings.ToLower(word)
+
+	if len(languages) == 0 {
+		languages = d.Languages()
+	}
+
+	synonyms := make([]string, 0)
+
+	for _, lang := range languages {
+
+		for _, s := range d.lookup[strings.ToLower(lang)][word] {
+
+			if !stringInList(s, synonyms) {
+				synonyms = append(synonyms, s)
+			}
+		}
+	}
+
+	sort.Strings(synonyms)
+	return synonyms
+}
+
+// expander returns a `tokenExpander` that expands search terms using the synonyms for 'languages' (or all languages if empty).
+// Terms are split in to words on anything that isn't a letter or a number, the same way the full-text tokenizer does, so
+// "N." is expanded to "north" and "St-Louis" to "saint louis".
+func (d *SynonymDictionary) expander(languages []string) tokenExpander {
+
+	return func(token string) []string {
+
+		words := splitSynonymWords(token)
+
+		if len(words) == 0 {
+			return nil
+		}
+
+		abbreviated := abbreviatedWords(token)
+
+		alternatives := make([][]string, len(words))
+		has_synonyms := false
+
+		for i, w := range words {
+
+			synonyms := d.wordSynonyms(w, abbreviated, languages...)
+
+			if len(synonyms) > 0 {
+				has_synonyms = true
+			}
+
+			alternatives[i] = append([]string{w}, synonyms...)
+		}
+
+		if !has_synonyms {
+			return nil
+		}
+
+		variants := [][]string{
+			[]string{},
+		}
+
+		for _, alts := range alternatives {
+
+			next := make([][]string, 0)
+
+			for _, v := range variants {
+
+				for _, a := range alts {
+
+					if len(next) == maxSynonymVariants+1 {
+						break
+					}
+
+					next = append(next, append(append([]string{}, v...), a))
+				}
+			}
+
+			variants = next
+		}
+
+		// The first variant is always the original words which are already matched
+		phrases := make([]string, 0)
+
+		for _, v := range variants[1:] {
+			phrases = append(phrases, strings.Join(v, " "))
+		}
+
+		return phrases
+	}
+}
+
+// expandNames returns the words equivalent to those in 'names', in any language, that are not already in 'names'.
+func (d *SynonymDictionary) expandNames(names string) []string {
+
+	words := splitSynonymWords(names)
+	abbreviated := abbreviatedWords(names)
+
+	expanded := make([]string, 0)
+
+	for _, w := range words {
+
+		for _, s := range d.wordSynonyms(w, abbreviated) {
+
+			if !stringInList(s, words) && !stringInList(s, expanded) {
+				expanded = append(expanded, s)
+			}
+		}
+	}
+
+	return expanded
+}
+
+// wordSynonyms returns the synonyms for 'word' in any of 'languages' used to expand search terms and names. Single-letter words
+// are easily confused with initials and stray letters so they are only expanded when they are listed in 'abbreviated', because
+// they were followed by a period, and are never added as the synonyms of other words. For example "N." is expanded to "north"
+// but neither "n" nor "north" is expanded to "n".
+func (d *SynonymDictionary) wordSynonyms(word string, abbreviated map[string]bool, languages ...string) []string {
+
+	if isSingleLetterWord(word) && !abbreviated[word] {
+		return nil
+	}
+
+	synonyms := make([]string, 0)
+
+	for _, s := range d.Synonyms(word, languages...) {
+
+		if !isSingleLetterWord(s) {
+			synonyms = append(synonyms, s)
+		}
+	}
+
+	return synonyms
+}
+
+// loadSynonymDictionary returns the `SynonymDictionary` defined by 'sources', each of which is "default" (the built-in
+// dictionary), "none" or the path to a file. The dictionaries for each source are merged. If 'sources' is empty or "none"
+// nil is returned.
+func loadSynonymDictionary(sources []string) (*SynonymDictionary, error) {
+
+	d := NewSynonymDictionary()
+	none := true
+
+	for _, src := range sources {
+
+		var src_d *SynonymDictionary
+		var err error
+
+		switch src {
+		case "none":
+			continue
+		case "default":
+			src_d, err = DefaultSynonymDictionary()
+		default:
+			src_d, err = NewSynonymDictionaryFromFile(src)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		d.Merge(src_d)
+		none = false
+	}
+
+	if none {
+		return nil, nil
+	}
+
+	return d, nil
+}
+
+// splitSynonymWords returns the lower-cased words in 'str', splitting on anything that isn't a letter or a number.
+func splitSynonymWords(str string) []string {
+
+	return strings.FieldsFunc(strings.ToLower(str), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// abbreviatedWords returns the lower-cased single-letter words in 'str' that are followed by a period, for example "n" in "N. Vancouver".
+func abbreviatedWords(str string) map[string]bool {
+
+	abbreviated := make(map[string]bool)
+	runes := []rune(strings.ToLower(str))
+
+	for i := 0; i+1 < len(runes); i++ {
+
+		if runes[i+1] != '.' || !isWordRune(runes[i]) {
+			continue
+		}
+
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		abbreviated[string(runes[i])] = true
+	}
+
+	return abbreviated
+}
+
+// isSingleLetterWord returns a boolean value indicating whether 'word' is a single letter or number.
+func isSingleLetterWord(word string) bool {
+	return len([]rune(word)) == 1
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// isSynonymWord returns a boolean value indicating whether 'str' is a single word made of letters and numbers.
+func isSynonymWord(str string) bool {
+
+	for _, r := range str {
+
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+			return false
+		}
+	}
+
+	return true
+}
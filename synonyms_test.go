@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+// synonymFeatures returns the features used to test synonym expansion.
+func synonymFeatures() [][]byte {
+
+	return [][]byte{
+		testFeature(1, "Saint Louis", 38.63, -90.20, nil),
+		testFeature(2, "St Louis Street", 38.60, -90.25, nil),
+		testFeature(3, "North Vancouver", 49.32, -123.07, nil),
+		testFeature(4, "N Vancouver Grill", 49.28, -123.12, nil),
+		testFeature(5, "Vancouver", 49.28, -123.12, nil),
+	}
+}
+
+func TestSynonymsDefault(t *testing.T) {
+
+	baseline := newTestDatabase(t, "", synonymFeatures()...)
+	none := newTestDatabase(t, "synonyms=none", synonymFeatures()...)
+
+	// Search terms are only expanded when the synonyms parameter is set
+
+	for _, term := range []string{"st louis", "saint louis", "N. Vancouver", "north vancouver", "vancouver"} {
+
+		ids := sortedIds(queryIds(t, baseline, term, nil))
+		expected := sortedIds(queryIds(t, none, term, nil))
+
+		if !equalIds(ids, expected) {
+			t.Errorf("Expected '%s' to match %v without synonyms but got %v", term, expected, ids)
+		}
+	}
+
+	ids := sortedIds(queryIds(t, baseline, "st louis", nil))
+
+	if !equalIds(ids, []string{"2"}) {
+		t.Errorf("Expected 'st louis' not to be expanded by default but got %v", ids)
+	}
+}
+
+func TestSynonymsExpansion(t *testing.T) {
+
+	ftdb := newTestDatabase(t, "synonyms=default", synonymFeatures()...)
+
+	tests := []struct {
+		Term     string
+		Expected []string
+	}{
+		{Term: "st louis", Expected: []string{"1", "2"}},
+		{Term: "saint louis", Expected: []string{"1", "2"}},
+		// Single-letter abbreviations are only expanded when they are followed by a period
+		{Term: "N. Vancouver", Expected: []string{"3", "4"}},
+		{Term: "n vancouver", Expected: []string{"4"}},
+		// and words are never expanded to single letters
+		{Term: "north vancouver", Expected: []string{"3"}},
+	}
+
+	for _, test := range tests {
+
+		ids := sortedIds(queryIds(t, ftdb, test.Term, nil))
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected '%s' to match %v but got %v", test.Term, test.Expected, ids)
+		}
+	}
+}
+
+func TestSynonymsSingleLetters(t *testing.T) {
+
+	d, err := DefaultSynonymDictionary()
+
+	if err != nil {
+		t.Fatalf("Failed to load default synonyms, %v", err)
+	}
+
+	expand := d.expander([]string{"eng"})
+
+	tests := map[string][]string{
+		"N.":    []string{"north"},
+		"n":     nil,
+		"north": nil,
+		"E.":    []string{"east"},
+		"St.":   []string{"saint", "street"},
+	}
+
+	for token, expected := range tests {
+
+		phrases := expand(token)
+
+		if len(phrases) != len(expected) {
+			t.Errorf("Expected '%s' to be expanded to %v but got %v", token, expected, phrases)
+			continue
+		}
+
+		for i, p := range phrases {
+
+			if p != expected[i] {
+				t.Errorf("Expected '%s' to be expanded to %v but got %v", token, expected, phrases)
+				break
+			}
+		}
+	}
+
+	// "North" is not expanded to "n" but "N." is expanded to the French "nord"
+
+	names := d.expandNames("North Vancouver, N. Vancouver")
+
+	if !equalIds(names, []string{"nord"}) {
+		t.Errorf("Expected no single-letter synonyms to be added to names but got %v", names)
+	}
+}
@@ -0,0 +1,253 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/sfomuseum/go-edtf"
+	"github.com/sfomuseum/go-edtf/parser"
+	wof_spr "github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"math"
+	"strings"
+	"time"
+)
+
+// TemporalConstraint defines which of a record's dates a `TemporalFilter` is tested against.
+type TemporalConstraint uint8
+
+const (
+	// ExistedConstraint matches records that existed at some point during a date or date range.
+	ExistedConstraint TemporalConstraint = iota
+	// InceptedConstraint matches records whose inception date falls within a date or date range.
+	InceptedConstraint
+	// CeasedConstraint matches records whose cessation date falls within a date or date range.
+	CeasedConstraint
+)
+
+// TemporalMode defines how uncertain, approximate and unknown dates are treated by a `TemporalFilter`.
+type TemporalMode uint8
+
+const (
+	// PossiblyMatches matches records whose dates may satisfy a constraint under any reading of those dates. Records with
+	// unknown dates are matched.
+	PossiblyMatches TemporalMode = iota
+	// DefinitelyMatches only matches records whose dates satisfy a constraint under every reading of those dates. Records
+	// with unknown dates are not matched.
+	DefinitelyMatches
+)
+
+// The lower and upper bounds used for open-ended or unknown dates.
+const (
+	minTime int64 = math.MinInt64
+	maxTime int64 = math.MaxInt64
+)
+
+// TemporalFilter matches records whose inception and cessation dates satisfy a constraint relative to an EDTF date or date range.
+// Dates are compared using the earliest and latest instants they may represent. Uncertain ("?") and approximate ("~") dates are
+// widened by one unit of their precision (day, month or year) in both directions and open-ended dates ("..") extend indefinitely.
+// Records whose dates can not be parsed, including the "uuuu" (unknown) value used by Who's On First, are treated as unknown.
+type TemporalFilter struct {
+	nullFilter
+	constraint TemporalConstraint
+	mode       TemporalMode
+	date       *edtf.EDTFDate
+	bounds     *dateBounds
+}
+
+// dateBounds are the earliest and latest instants, in seconds since the Unix epoch, that an EDTF date may represent.
+type dateBounds struct {
+	lower int64
+	upper int64
+	// False if either bound could not be determined, in which case it is set to minTime or maxTime.
+	known bool
+	// True if the date is open (".."). Open inception dates extend indefinitely in to the past and open cessation dates
+	// (the record still exists) extend indefinitely in to the future.
+	open bool
+}
+
+// NewTemporalFilter returns a new `TemporalFilter` that tests records against 'edtf_str' according to 'constraint' and 'mode'.
+// 'edtf_str' may be any EDTF date or interval supported by go-edtf, for example "1950", "1950-06~" or "1990/2000".
+func NewTemporalFilter(constraint TemporalConstraint, edtf_str string, mode TemporalMode) (*TemporalFilter, error) {
+
+	d, err := parser.ParseString(edtf_str)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid EDTF date '%s', %w", edtf_str, err)
+	}
+
+	b := boundsForDate(d)
+
+	if !b.known {
+		return nil, fmt.Errorf("Invalid EDTF date '%s', bounds can not be determined", edtf_str)
+	}
+
+	f := &TemporalFilter{
+		constraint: constraint,
+		mode:       mode,
+		date:       d,
+		bounds:     b,
+	}
+
+	return f, nil
+}
+
+// ParseTemporalMode returns the `TemporalMode` for 'str' which is expected to be "possibly" or "definitely".
+func ParseTemporalMode(str string) (TemporalMode, error) {
+
+	switch strings.ToLower(str) {
+	case "possibly", "":
+		return PossiblyMatches, nil
+	case "definitely":
+		return DefinitelyMatches, nil
+	default:
+		return 0, fmt.Errorf("Invalid temporal mode '%s'", str)
+	}
+}
+
+func (m TemporalMode) String() string {
+
+	switch m {
+	case DefinitelyMatches:
+		return "definitely"
+	default:
+		return "possibly"
+	}
+}
+
+func (c TemporalConstraint) String() string {
+
+	switch c {
+	case InceptedConstraint:
+		return "incepted"
+	case CeasedConstraint:
+		return "ceased"
+	default:
+		return "existed"
+	}
+}
+
+func (f *TemporalFilter) MatchesSPR(s wof_spr.StandardPlacesResult) bool {
+
+	inception := boundsForDate(s.Inception())
+	cessation := boundsForDate(s.Cessation())
+
+	if inception.open {
+		inception.upper = minTime
+	}
+
+	if cessation.open {
+		cessation.lower = maxTime
+	}
+
+	q := f.bounds
+
+	switch f.constraint {
+	case InceptedConstraint:
+		return f.matchesDate(inception, q)
+	case CeasedConstraint:
+
+		// An open cessation date means the record hasn't ceased
+		if cessation.open {
+			return false
+		}
+
+		return f.matchesDate(cessation, q)
+
+	default:
+
+		if f.mode == DefinitelyMatches {
+
+			if !inception.known || !cessation.known {
+				return false
+			}
+
+			// The period during which the record definitely existed is from the latest possible inception
+			// to the earliest possible cessation.
+			return inception.upper <= cessation.lower && inception.upper <= q.upper && cessation.lower >= q.lower
+		}
+
+		return inception.lower <= q.upper && cessation.upper >= q.lower
+	}
+}
+
+// matchesDate returns a boolean value indicating whether 'd' falls within 'q'.
+func (f *TemporalFilter) matchesDate(d *dateBounds, q *dateBounds) bool {
+
+	if f.mode == DefinitelyMatches {
+		return d.known && d.lower >= q.lower && d.upper <= q.upper
+	}
+
+	return d.lower <= q.upper && d.upper >= q.lower
+}
+
+func (f *TemporalFilter) String() string {
+	return fmt.Sprintf("%s=%s;mode=%s", f.constraint, f.date.EDTF, f.mode)
+}
+
+// boundsForDate returns the earliest and latest instants that 'd' may represent. If 'd' is nil, or either bound can not
+// be determined, then the bounds are unknown.
+func boundsForDate(d *edtf.EDTFDate) *dateBounds {
+
+	b := &dateBounds{
+		lower: minTime,
+		upper: maxTime,
+	}
+
+	if d == nil || d.Start == nil || d.End == nil {
+		return b
+	}
+
+	if edtf.IsOpen(d.EDTF) {
+		b.known = true
+		b.open = true
+		return b
+	}
+
+	lower, lower_ok := boundForDate(d.Start.Lower, -1)
+	upper, upper_ok := boundForDate(d.End.Upper, 1)
+
+	b.lower = lower
+	b.upper = upper
+	b.known = lower_ok && upper_ok
+
+	return b
+}
+
+// boundForDate returns the instant for 'd', widened in the direction of 'sign' if 'd' is uncertain or approximate, and
+// a boolean value indicating whether it is known. Open dates return minTime or maxTime depending on 'sign'.
+func boundForDate(d *edtf.Date, sign int) (int64, bool) {
+
+	unbounded := minTime
+
+	if sign > 0 {
+		unbounded = maxTime
+	}
+
+	if d == nil {
+		return unbounded, false
+	}
+
+	if d.Timestamp == nil {
+		return unbounded, d.Open
+	}
+
+	t := *d.Timestamp.Time()
+
+	qualifiers := d.Uncertain | d.Approximate
+
+	switch {
+	case qualifiers == edtf.NONE:
+		// pass
+	case qualifiers.HasFlag(edtf.MILLENIUM), qualifiers.HasFlag(edtf.CENTURY), qualifiers.HasFlag(edtf.DECADE), qualifiers.HasFlag(edtf.YEAR):
+		t = t.AddDate(sign, 0, 0)
+	case qualifiers.HasFlag(edtf.MONTH):
+		t = t.AddDate(0, sign, 0)
+	default:
+		t = t.AddDate(0, 0, sign)
+	}
+
+	if sign > 0 {
+		year, month, day := t.Date()
+		t = time.Date(year, month, day, 23, 59, 59, 0, time.UTC)
+	}
+
+	return t.Unix(), true
+}
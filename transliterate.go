@@ -0,0 +1,492 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	aa_sqlite "github.com/aaronland/go-sqlite"
+	"github.com/whosonfirst/go-whosonfirst-feature/alt"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+	"github.com/whosonfirst/go-whosonfirst-names/tags"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Transliterator converts text written in a given script to the Latin script.
+type Transliterator interface {
+	// Transliterate returns the Latin transliteration of 'text' and a boolean value indicating whether every letter
+	// in 'text' could be transliterated.
+	Transliterate(text string) (string, bool)
+}
+
+// TransliteratorFunc is a function that implements the `Transliterator` interface.
+type TransliteratorFunc func(text string) (string, bool)
+
+func (f TransliteratorFunc) Transliterate(text string) (string, bool) {
+	return f(text)
+}
+
+// Transliteration is the Latin form of a record's name indexed alongside its other names.
+type Transliteration struct {
+	// The original name.
+	Name string `json:"name"`
+	// The ISO 15924 code for the script of the original name, for example "Cyrl".
+	Script string `json:"script"`
+	// The Latin transliteration of the name.
+	Transliteration string `json:"transliteration"`
+}
+
+// scriptTable maps an ISO 15924 script code to its Unicode range table.
+type scriptTable struct {
+	code  string
+	table *unicode.RangeTable
+}
+
+// The scripts that are detected in names which are not tagged with a script. Latin is always listed first.
+var scriptTables = []scriptTable{
+	{"Latn", unicode.Latin},
+	{"Cyrl", unicode.Cyrillic},
+	{"Grek", unicode.Greek},
+	{"Hang", unicode.Hangul},
+	{"Hira", unicode.Hiragana},
+	{"Kana", unicode.Katakana},
+	{"Hani", unicode.Han},
+	{"Arab", unicode.Arabic},
+	{"Hebr", unicode.Hebrew},
+	{"Armn", unicode.Armenian},
+	{"Geor", unicode.Georgian},
+	{"Thai", unicode.Thai},
+	{"Deva", unicode.Devanagari},
+}
+
+var transliterators map[string]Transliterator
+
+var transliterators_mu *sync.RWMutex
+
+func init() {
+
+	transliterators_mu = new(sync.RWMutex)
+
+	transliterators = map[string]Transliterator{
+		"Cyrl": TransliteratorFunc(transliterateCyrillic),
+		"Grek": TransliteratorFunc(transliterateGreek),
+		"Hang": TransliteratorFunc(transliterateHangul),
+		"Hira": TransliteratorFunc(transliterateKana),
+		"Kana": TransliteratorFunc(transliterateKana),
+		"Hani": TransliteratorFunc(transliterateHan),
+		"Hans": TransliteratorFunc(transliterateHan),
+		"Hant": TransliteratorFunc(transliterateHan),
+	}
+}
+
+// RegisterTransliterator registers 't' as the `Transliterator` for 'script' which is an ISO 15924 script code, for example
+// "Hani", replacing any existing transliterator. Transliterators for Cyrillic, Greek, Hangul, Hiragana, Katakana and Han are
+// registered by default. The Han transliterator produces (Mandarin) Pinyin for the characters commonly found in Chinese place
+// names only and may be replaced by one which uses a complete dictionary.
+func RegisterTransliterator(script string, t Transliterator) {
+
+	transliterators_mu.Lock()
+	defer transliterators_mu.Unlock()
+
+	transliterators[normalizeScript(script)] = t
+}
+
+// Transliterate returns the Latin transliteration of 'text' and a boolean value indicating whether it could be transliterated.
+// 'script' is the ISO 15924 code for the script of 'text' and may be empty in which case it is detected from the letters in
+// 'text'. Text that is already written in the Latin script is never transliterated.
+func Transliterate(text string, script string) (string, bool) {
+
+	if script == "" {
+		script = detectScript(text)
+	}
+
+	script = normalizeScript(script)
+
+	if script == "" || script == "Latn" {
+		return "", false
+	}
+
+	transliterators_mu.RLock()
+	t, ok := transliterators[script]
+	transliterators_mu.RUnlock()
+
+	if !ok {
+		return "", false
+	}
+
+	latin, ok := t.Transliterate(text)
+
+	if !ok {
+		return "", false
+	}
+
+	latin = strings.Join(strings.Fields(latin), " ")
+
+	if latin == "" {
+		return "", false
+	}
+
+	return latin, true
+}
+
+// detectScript returns the ISO 15924 code for the first script, other than Latin, used by the letters in 'text'. If every letter
+// is Latin it returns "Latn" and if no script can be detected it returns an empty string.
+func detectScript(text string) string {
+
+	latin := false
+
+	for _, r := range text {
+
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		for _, st := range scriptTables {
+
+			if !unicode.Is(st.table, r) {
+				continue
+			}
+
+			if st.code == "Latn" {
+				latin = true
+				break
+			}
+
+			return st.code
+		}
+	}
+
+	if latin {
+		return "Latn"
+	}
+
+	return ""
+}
+
+// normalizeScript returns 'script' in title case, for example "cyrl" becomes "Cyrl".
+func normalizeScript(script string) string {
+
+	if script == "" {
+		return ""
+	}
+
+	script = strings.ToLower(script)
+	return strings.ToUpper(script[:1]) + script[1:]
+}
+
+// transliterationExpander returns a `tokenExpander` that adds the Latin transliteration of search terms written in other scripts.
+func transliterationExpander() tokenExpander {
+
+	return func(token string) []string {
+
+		latin, ok := Transliterate(token, "")
+
+		if !ok {
+			return nil
+		}
+
+		words := splitSynonymWords(latin)
+
+		if len(words) == 0 {
+			return nil
+		}
+
+		return []string{
+			strings.Join(words, " "),
+		}
+	}
+}
+
+// featureTransliterations returns the Latin transliterations of the (default and language-specific) names of 'f'. The script
+// of each name is read from the script subtag of its language tag, where present, and otherwise detected from its letters.
+// Japanese and Korean names written in Han characters are not transliterated since their readings are not Pinyin.
+func featureTransliterations(f []byte) ([]*Transliteration, error) {
+
+	names := map[string][]string{}
+
+	name, err := properties.Name(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names[""] = []string{name}
+
+	for tag, tag_names := range properties.Names(f) {
+		names[tag] = tag_names
+	}
+
+	tag_keys := make([]string, 0)
+
+	for tag, _ := range names {
+		tag_keys = append(tag_keys, tag)
+	}
+
+	sort.Strings(tag_keys)
+
+	seen := make(map[string]bool)
+	results := make([]*Transliteration, 0)
+
+	for _, tag := range tag_keys {
+
+		tag_script := ""
+		tag_language := ""
+
+		if tag != "" {
+
+			lt, err := tags.NewLangTag(tag)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to create new lang tag for '%s', %w", tag, err)
+			}
+
+			tag_script = lt.Script()
+			tag_language = lt.Language()
+		}
+
+		for _, n := range names[tag] {
+
+			if seen[n] {
+				continue
+			}
+
+			script := tag_script
+
+			if script == "" {
+				script = detectScript(n)
+			}
+
+			if normalizeScript(script) == "Hani" && (tag_language == "jpn" || tag_language == "kor") {
+				continue
+			}
+
+			latin, ok := Transliterate(n, script)
+
+			if !ok {
+				continue
+			}
+
+			seen[n] = true
+
+			results = append(results, &Transliteration{
+				Name:            n,
+				Script:          normalizeScript(script),
+				Transliteration: latin,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// indexTransliterations records the transliterations of the names of 'f' in the transliterations table and appends them
+// to the names indexed in the search table.
+func (ftdb *SQLiteFullTextDatabase) indexTransliterations(ctx context.Context, id int64, f []byte) error {
+
+	err := ftdb.transliterations_table.IndexRecord(ctx, ftdb.db, f)
+
+	if err != nil {
+		return err
+	}
+
+	forms, err := featureTransliterations(f)
+
+	if err != nil {
+		return err
+	}
+
+	expand := func(names string) []string {
+
+		lower := strings.ToLower(names)
+		expanded := make([]string, 0)
+
+		for _, t := range forms {
+
+			if !strings.Contains(lower, t.Transliteration) && !stringInList(t.Transliteration, expanded) {
+				expanded = append(expanded, t.Transliteration)
+			}
+		}
+
+		return expanded
+	}
+
+	return ftdb.appendIndexedNames(ctx, id, expand)
+}
+
+// Transliterations returns the Latin transliterations indexed for the record 'id'. Transliterations are only indexed if
+// the database was created with the `transliterate` parameter.
+func (ftdb *SQLiteFullTextDatabase) Transliterations(ctx context.Context, id int64) ([]*Transliteration, error) {
+
+	conn, err := ftdb.db.Conn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ftdb.requireTables(ctx, conn, "transliterations", ftdb.transliterations_table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := fmt.Sprintf("SELECT name, script, transliteration FROM %s WHERE id = ? ORDER BY name", ftdb.transliterations_table.Name())
+
+	rows, err := conn.QueryContext(ctx, q, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := make([]*Transliteration, 0)
+
+	for rows.Next() {
+
+		t := new(Transliteration)
+
+		err := rows.Scan(&t.Name, &t.Script, &t.Transliteration)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, t)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// transliterationsTable implements the `aa_sqlite.Table` interface for the Latin transliterations of the names of each record.
+// Each transliteration is stored as a distinct row so that it can be told apart from the record's own names.
+type transliterationsTable struct {
+	aa_sqlite.Table
+	name string
+}
+
+// newTransliterationsTableWithDatabase returns a new `transliterationsTable` instance, creating the table in 'db' if necessary.
+func newTransliterationsTableWithDatabase(ctx context.Context, db aa_sqlite.Database) (aa_sqlite.Table, error) {
+
+	t, err := newTransliterationsTable(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.InitializeTable(ctx, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// newTransliterationsTable returns a new `transliterationsTable` instance.
+func newTransliterationsTable(ctx context.Context) (aa_sqlite.Table, error) {
+
+	t := &transliterationsTable{
+		name: "transliterations",
+	}
+
+	return t, nil
+}
+
+func (t *transliterationsTable) Name() string {
+	return t.name
+}
+
+func (t *transliterationsTable) Schema() string {
+
+	schema := `CREATE TABLE %s (
+		id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		script TEXT NOT NULL,
+		transliteration TEXT NOT NULL,
+		PRIMARY KEY (id, name)
+	);`
+
+	return fmt.Sprintf(schema, t.Name())
+}
+
+func (t *transliterationsTable) InitializeTable(ctx context.Context, db aa_sqlite.Database) error {
+	return aa_sqlite.CreateTableIfNecessary(ctx, db, t)
+}
+
+// IndexRecord replaces the transliterations for the feature 'i', which is expected to be a GeoJSON Feature encoded as a byte
+// slice. Alternate geometries are ignored.
+func (t *transliterationsTable) IndexRecord(ctx context.Context, db aa_sqlite.Database, i interface{}) error {
+
+	f, ok := i.([]byte)
+
+	if !ok {
+		return fmt.Errorf("Unsupported record type %T", i)
+	}
+
+	if alt.IsAlt(f) {
+		return nil
+	}
+
+	id, err := properties.Id(f)
+
+	if err != nil {
+		return err
+	}
+
+	forms, err := featureTransliterations(f)
+
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn()
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	err = t.replaceTransliterations(ctx, tx, id, forms)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (t *transliterationsTable) replaceTransliterations(ctx context.Context, tx *sql.Tx, id int64, forms []*Transliteration) error {
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", t.Name()), id)
+
+	if err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf("INSERT OR REPLACE INTO %s (id, name, script, transliteration) VALUES (?, ?, ?, ?)", t.Name())
+
+	for _, tr := range forms {
+
+		_, err := tx.ExecContext(ctx, q, id, tr.Name, tr.Script, tr.Transliteration)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"testing"
+)
+
+func TestTransliterateHan(t *testing.T) {
+
+	tests := map[string]string{
+		"北京":   "beijing",
+		"北京市":  "beijing shi",
+		"上海":   "shanghai",
+		"重庆":   "chongqing",
+		"廣州":   "guangzhou",
+		"臺北市":  "taibei shi",
+		"西安":   "xian",
+		"乌鲁木齐": "wulumuqi",
+	}
+
+	for han, expected := range tests {
+
+		latin, ok := Transliterate(han, "")
+
+		if !ok {
+			t.Errorf("Failed to transliterate '%s'", han)
+			continue
+		}
+
+		if latin != expected {
+			t.Errorf("Expected '%s' to be transliterated as '%s' but got '%s'", han, expected, latin)
+		}
+	}
+
+	// Names containing characters which aren't known are not transliterated rather than partially transliterated
+
+	_, ok := Transliterate("北京饭店", "")
+
+	if ok {
+		t.Errorf("Expected '北京饭店' not to be transliterated")
+	}
+}
+
+func TestTransliteratedHanQueries(t *testing.T) {
+
+	features := [][]byte{
+		testFeature(1, "北京", 39.91, 116.39, map[string]interface{}{
+			"name:zho_x_preferred": []string{"北京市"},
+		}),
+		testFeature(2, "Tokyo", 35.69, 139.69, map[string]interface{}{
+			"name:jpn_x_preferred": []string{"東京"},
+		}),
+	}
+
+	ftdb := newTestDatabase(t, "transliterate=true", features...)
+
+	tests := []struct {
+		Term     string
+		Expected []string
+	}{
+		{Term: "Beijing", Expected: []string{"1"}},
+		{Term: "beijing shi", Expected: []string{"1"}},
+		{Term: "北京", Expected: []string{"1"}},
+		// Japanese names are not transliterated as Pinyin
+		{Term: "dongjing", Expected: []string{}},
+		{Term: "東京", Expected: []string{"2"}},
+	}
+
+	for _, test := range tests {
+
+		ids := sortedIds(queryIds(t, ftdb, test.Term, nil))
+
+		if !equalIds(ids, test.Expected) {
+			t.Errorf("Expected '%s' to match %v but got %v", test.Term, test.Expected, ids)
+		}
+	}
+}